@@ -0,0 +1,179 @@
+package gvm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/files"
+	"github.com/zooyer/gvm/interval/golang"
+	"github.com/zooyer/gvm/interval/paths"
+	"github.com/zooyer/gvm/interval/utils"
+)
+
+// Adoption is a go installation outside of GOHOME, used in place.
+type Adoption struct {
+	Root string    `json:"root"`
+	Time time.Time `json:"time"`
+}
+
+func (m *Manager) adoptedFile() string {
+	return filepath.Join(m.home, "adopted.json")
+}
+
+// adopted returns the shared map of the adopted installations, loading it
+// on first use. m.mu must be held.
+func (m *Manager) adopted() map[string]Adoption {
+	if m.adoptions == nil {
+		m.adoptions = make(map[string]Adoption)
+		if err := files.ReadJSON(m.adoptedFile(), &m.adoptions); err != nil && !os.IsNotExist(err) {
+			debug.Println("gvm: read adopted error:", err.Error())
+		}
+	}
+	return m.adoptions
+}
+
+// Adopted returns a copy of the adopted installations by version.
+func (m *Manager) Adopted() map[string]Adoption {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var adoptions = make(map[string]Adoption, len(m.adopted()))
+	for version, a := range m.adopted() {
+		adoptions[version] = a
+	}
+	return adoptions
+}
+
+// adoption returns the adopted installation of version.
+func (m *Manager) adoption(version string) (a Adoption, exists bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, exists = m.adopted()[version]
+	return
+}
+
+// Unadopt unregisters the adopted version, the installation is kept.
+func (m *Manager) Unadopt(version string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var adoptions = m.adopted()
+	delete(adoptions, version)
+	return files.WriteJSON(m.adoptedFile(), adoptions, 0644)
+}
+
+// isVersionDir reports whether name is a dir gvm installs into GOHOME.
+func isVersionDir(name string) bool {
+	if _, err := golang.ParseVersion(name); err == nil {
+		return true
+	}
+	return IsBuild(name) || strings.HasPrefix(name, StagingPrefix)
+}
+
+// rootGoVersion returns the release version reported by bin/go of root.
+func rootGoVersion(root string) (version string, err error) {
+	binary := filepath.Join(root, "bin", "go")
+	out, err := utils.Command(binary, "version")
+	if err != nil {
+		return "", fmt.Errorf("%s version: %w", binary, err)
+	}
+
+	field := strings.Fields(out)
+	if len(field) < 4 || field[0] != "go" || field[1] != "version" {
+		return "", fmt.Errorf("%s version: unexpected output %q", binary, strings.TrimSpace(out))
+	}
+	if _, err = golang.ParseVersion(field[2]); err != nil {
+		return "", fmt.Errorf("%s version: %w, only releases can be adopted", binary, err)
+	}
+	if platform := runtime.GOOS + "/" + runtime.GOARCH; field[3] != platform {
+		return "", fmt.Errorf("%s version: %s, expected %s", binary, field[3], platform)
+	}
+
+	return field[2], nil
+}
+
+// Adopt registers the go installation root by its version, so it is
+// selected like an installed version without being copied, and returns
+// "adopted", "already adopted" or "already installed".
+func (m *Manager) Adopt(root string) (version, status string, err error) {
+	if root, err = files.RealPath(root); err != nil {
+		return
+	}
+
+	// GOHOME may itself be a go installation, like the default
+	// /usr/local/go, only the trees gvm installed in it are refused
+	if home, err := files.RealPath(m.home); err == nil {
+		if rel, err := filepath.Rel(home, root); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			if name := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]; isVersionDir(name) {
+				return "", "", fmt.Errorf("%s: installed by gvm as %s", root, name)
+			}
+		}
+	}
+
+	if version, err = rootGoVersion(root); err != nil {
+		return
+	}
+
+	if files.IsDir(filepath.Join(m.home, version)) {
+		return version, "already installed", nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var adoptions = m.adopted()
+	if a, exists := adoptions[version]; exists {
+		if a.Root == root {
+			return version, "already adopted", nil
+		}
+		if files.IsDir(a.Root) {
+			return "", "", fmt.Errorf("%s is adopted from %s", version, a.Root)
+		}
+	}
+
+	adoptions[version] = Adoption{Root: root, Time: time.Now()}
+	if err = files.WriteJSON(m.adoptedFile(), adoptions, 0644); err != nil {
+		return
+	}
+
+	return version, "adopted", nil
+}
+
+// Scan returns the existing usual locations of go installations: the
+// default install dir, distribution packages, the sdk dir of golang.org/dl
+// wrappers, GOROOT and the extra roots.
+func (m *Manager) Scan(extra ...string) (roots []string) {
+	var patterns = append([]string{
+		golang.DefaultGoHome(),
+		"/usr/lib/go",
+		"/usr/lib/go-*",
+		"/usr/local/opt/go/libexec",
+		"/opt/homebrew/opt/go/libexec",
+		paths.Home("sdk", "go*"),
+		os.Getenv("GOROOT"),
+	}, extra...)
+
+	var seen = make(map[string]bool)
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			root, err := files.RealPath(match)
+			if err != nil || seen[root] || !files.IsDir(root) {
+				continue
+			}
+			seen[root] = true
+			roots = append(roots, root)
+		}
+	}
+
+	return
+}
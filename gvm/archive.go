@@ -0,0 +1,55 @@
+package gvm
+
+import (
+	"time"
+
+	"github.com/zooyer/gvm/interval/cache"
+)
+
+// Archive is a release archive in the archive cache, downloaded or
+// partially downloaded.
+type Archive struct {
+	Sha256  string    `json:"sha256"`
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Used    time.Time `json:"used"`
+	Partial bool      `json:"partial"`
+}
+
+func toArchives(entries []cache.Entry) []Archive {
+	var archives = make([]Archive, 0, len(entries))
+	for _, entry := range entries {
+		archives = append(archives, Archive{
+			Sha256:  entry.Sha256,
+			Name:    entry.Name,
+			Size:    entry.Size,
+			Used:    entry.Used,
+			Partial: entry.Partial,
+		})
+	}
+	return archives
+}
+
+// CacheDir returns the archive cache directory of m.
+func (m *Manager) CacheDir() string {
+	return m.cache.Dir
+}
+
+// Archives returns the archives in the cache, most recently used first.
+func (m *Manager) Archives() ([]Archive, error) {
+	entries, err := m.cache.List()
+	return toArchives(entries), err
+}
+
+// PruneArchives removes the least recently used archives until the cache
+// is at most max bytes, and returns the removed ones.
+func (m *Manager) PruneArchives(max int64) ([]Archive, error) {
+	removed, err := m.cache.Prune(max)
+	return toArchives(removed), err
+}
+
+// CleanArchives removes all archives and returns them.
+func (m *Manager) CleanArchives() ([]Archive, error) {
+	removed, err := m.cache.Clean()
+	return toArchives(removed), err
+}
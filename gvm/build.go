@@ -0,0 +1,336 @@
+package gvm
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/files"
+	"github.com/zooyer/gvm/interval/git"
+	"github.com/zooyer/gvm/interval/golang"
+	"github.com/zooyer/gvm/interval/utils"
+)
+
+// build prefixes name the development builds, followed by the commit.
+const (
+	tipPrefix = "gotip-"
+	gitPrefix = "gogit-"
+)
+
+// Build records the source of a development build, the ref of the git
+// repository URL and its commit.
+type Build struct {
+	URL    string    `json:"url"`
+	Ref    string    `json:"ref"`
+	Commit string    `json:"commit"`
+	Time   time.Time `json:"time"`
+}
+
+func (b Build) spec() git.Spec {
+	return git.Spec{URL: b.URL, Ref: b.Ref}
+}
+
+func (m *Manager) buildsFile() string {
+	return filepath.Join(m.home, "builds.json")
+}
+
+// Builds returns the development builds by version.
+func (m *Manager) Builds() map[string]Build {
+	var builds = make(map[string]Build)
+	if err := files.ReadJSON(m.buildsFile(), &builds); err != nil && !os.IsNotExist(err) {
+		debug.Println("gvm: read builds error:", err.Error())
+	}
+	return builds
+}
+
+// IsBuild reports whether version names a development build.
+func IsBuild(version string) bool {
+	return strings.HasPrefix(version, tipPrefix) || strings.HasPrefix(version, gitPrefix)
+}
+
+// IsBuildQuery reports whether query asks for a development build.
+func IsBuildQuery(query string) bool {
+	return query == "tip" || strings.HasPrefix(query, "git:")
+}
+
+// ResolveBuild resolves tip to the newest installed tip build, and an
+// installed build name to itself.
+func (m *Manager) ResolveBuild(query string) (version string, ok bool) {
+	if IsBuild(query) {
+		return query, true
+	}
+	if query != "tip" {
+		return "", false
+	}
+
+	var newest time.Time
+	for name, info := range m.Builds() {
+		if strings.HasPrefix(name, tipPrefix) && info.Time.After(newest) && files.IsDir(m.Root(name)) {
+			version, newest = name, info.Time
+		}
+	}
+
+	return version, version != ""
+}
+
+// bootstrap returns the GOROOT building version: the given version or
+// directory, or the oldest installed version satisfying the bootstrap
+// rules of version. It is empty if version needs no bootstrap.
+func (m *Manager) bootstrap(version, given string) (root string, err error) {
+	if given != "" {
+		if files.IsDir(given) {
+			return given, nil
+		}
+		if given, err = m.Resolve(given); err != nil {
+			return
+		}
+		if !m.Exists(given) {
+			return "", fmt.Errorf("bootstrap %s is not installed", given)
+		}
+		return m.Root(given), nil
+	}
+
+	name, err := golang.Bootstrap(version, m.Installed())
+	if err != nil {
+		return "", fmt.Errorf("%w, install one or choose the bootstrap", err)
+	}
+	if name == "" {
+		return "", nil
+	}
+
+	return m.Root(name), nil
+}
+
+// build runs make.bash in the go tree root with the bootstrap GOROOT, the
+// output is written to a log file in GOHOME/logs.
+func (m *Manager) build(version, root, bootstrap string) (err error) {
+	var logs = filepath.Join(m.home, "logs")
+	if err = os.MkdirAll(logs, 0755); err != nil {
+		return
+	}
+
+	var filename = filepath.Join(logs, fmt.Sprintf("%s-%s.log", version, time.Now().Format("20060102-150405")))
+	file, err := os.Create(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	var env = Override(map[string]string{
+		"GOROOT":           "",
+		"GOROOT_BOOTSTRAP": bootstrap,
+		"GOBIN":            "",
+		"GOTOOLCHAIN":      "local",
+	})
+
+	var name, args = "./make.bash", []string(nil)
+	if runtime.GOOS == "windows" {
+		name, args = "cmd", []string{"/c", "make.bat"}
+	}
+
+	if bootstrap == "" {
+		bootstrap = "the C toolchain"
+	}
+	fmt.Fprintln(m.out, version, "building with", bootstrap+", log:", filename)
+
+	if err = utils.RunIn(filepath.Join(root, "src"), env, file, name, args...); err != nil {
+		return fmt.Errorf("make: %w, see %s", err, filename)
+	}
+
+	return nil
+}
+
+// buildSpec returns the git source of a build query: tip, git:<url>@<ref>
+// or the name of an installed build.
+func (m *Manager) buildSpec(query string) (spec git.Spec, prefix string, err error) {
+	if query == "tip" {
+		return git.Spec{URL: m.gitRemote, Ref: "HEAD"}, tipPrefix, nil
+	}
+
+	if spec, ok := git.ParseSpec(query); ok {
+		return spec, gitPrefix, nil
+	}
+
+	if info, exists := m.Builds()[query]; exists && IsBuild(query) {
+		prefix = gitPrefix
+		if strings.HasPrefix(query, tipPrefix) {
+			prefix = tipPrefix
+		}
+		return info.spec(), prefix, nil
+	}
+
+	return spec, "", fmt.Errorf("%s: not tip, git:<url>@<ref> or an installed build", query)
+}
+
+// InstallBuild fetches a development build query, tip, git:<url>@<ref> or
+// an installed build, into a bare repository in GOHOME/src, builds its
+// commit and installs it named by the commit. An installed build is
+// updated to the newest commit of its ref.
+func (m *Manager) InstallBuild(ctx context.Context, query string, opts InstallOptions) (version, status string, err error) {
+	spec, prefix, err := m.buildSpec(query)
+	if err != nil {
+		return
+	}
+
+	return m.installBuild(ctx, spec, prefix, opts)
+}
+
+// installBuild installs the build of spec, named by prefix and the commit.
+func (m *Manager) installBuild(ctx context.Context, spec git.Spec, prefix string, opts InstallOptions) (version, status string, err error) {
+	var name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, spec.URL)
+	var repo = filepath.Join(m.home, "src", name+".git")
+
+	fmt.Fprintln(m.out, "fetching:", spec)
+	hash, err := git.Fetch(repo, spec, m.out)
+	if err != nil {
+		return
+	}
+
+	if version = fmt.Sprintf("%s%.12s", prefix, hash); m.Exists(version) {
+		return version, "already installed", nil
+	}
+
+	staging, err := m.stage(version)
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	var root = filepath.Join(staging, "go")
+	if err = git.Archive(repo, hash, root); err != nil {
+		return
+	}
+
+	base, err := golang.TreeVersion(root)
+	if err != nil {
+		return
+	}
+
+	// without .git, make.bash takes the version from the VERSION file
+	if !files.IsFile(filepath.Join(root, "VERSION")) {
+		if err = ioutil.WriteFile(filepath.Join(root, "VERSION"), []byte("devel "+version+"\n"), 0644); err != nil {
+			return
+		}
+	}
+
+	boot, err := m.bootstrap(base, opts.Bootstrap)
+	if err != nil {
+		return
+	}
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	if err = m.build(version, root, boot); err != nil {
+		return
+	}
+
+	if err = m.commit(ctx, staging, root, version); err != nil {
+		return
+	}
+
+	var builds = m.Builds()
+	builds[version] = Build{URL: spec.URL, Ref: spec.Ref, Commit: hash, Time: time.Now()}
+	if err = files.WriteJSON(m.buildsFile(), builds, 0644); err != nil {
+		return
+	}
+
+	return version, "installed", nil
+}
+
+// InstallSource builds a go version from source and installs it. source
+// is a checkout of the go repository, or a version query whose source
+// archive is downloaded. A git checkout without a VERSION file, like the
+// main branch or a fork, is installed as a development build of its
+// committed HEAD.
+func (m *Manager) InstallSource(ctx context.Context, source string, opts InstallOptions) (version, status string, err error) {
+	var checkout string
+	if files.IsDir(source) {
+		checkout = source
+		if version, err = golang.RootVersion(checkout); err != nil {
+			if !files.Exists(filepath.Join(checkout, ".git")) {
+				return "", "", fmt.Errorf("%s: no VERSION file and no git checkout", checkout)
+			}
+			if checkout, err = filepath.Abs(checkout); err != nil {
+				return
+			}
+			return m.installBuild(ctx, git.Spec{URL: checkout, Ref: "HEAD"}, gitPrefix, opts)
+		}
+	} else if version, err = m.Resolve(source); err != nil {
+		return
+	}
+
+	if m.Exists(version) {
+		return version, "already installed", nil
+	}
+
+	boot, err := m.bootstrap(version, opts.Bootstrap)
+	if err != nil {
+		return
+	}
+
+	staging, err := m.stage(version)
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	var root = filepath.Join(staging, "go")
+	if checkout != "" {
+		fmt.Fprintln(m.out, version, "copying: ")
+		if err = files.CopyDir(checkout, root); err != nil {
+			return
+		}
+	} else {
+		var archive golang.Version
+		if archive, err = m.index.FindSource(version); err != nil {
+			return
+		}
+		if archive.Sha256 == "" {
+			return "", "", fmt.Errorf("%s: no sha256 checksum published, refusing to install", archive.Name)
+		}
+
+		var filename = m.cache.Path(archive.Sha256, archive.Name)
+		if _, cached := m.cache.Lookup(archive.Sha256, archive.Name); !cached {
+			fmt.Fprintln(m.out, version, "downloading: ")
+			if err = m.mirrors(ctx, archive.Name, nil, func(url string) error {
+				return m.downloader.Download(ctx, url, filename, archive.Sha256, nil)
+			}); err != nil {
+				return
+			}
+		}
+
+		fmt.Fprintln(m.out, version, "unpacking: ")
+		var bar, done = m.bar(nil)
+		err = golang.Decode(filename, staging, bar)
+		if done(); err != nil {
+			return
+		}
+	}
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	if err = m.build(version, root, boot); err != nil {
+		return
+	}
+
+	if err = m.commit(ctx, staging, root, version); err != nil {
+		return
+	}
+
+	return version, "installed", nil
+}
@@ -0,0 +1,312 @@
+package gvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/zooyer/gvm/interval/cache"
+	"github.com/zooyer/gvm/interval/conf"
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/files"
+	"github.com/zooyer/gvm/interval/golang"
+	"github.com/zooyer/gvm/interval/paths"
+	"github.com/zooyer/gvm/interval/utils"
+)
+
+// Finding is a problem found by Doctor with a suggested fix, and the fix
+// itself if gvm can apply it.
+type Finding struct {
+	Problem string
+	Suggest string
+	Fix     func() error
+}
+
+// minFree is the free space in GOHOME needed to install a version.
+const minFree = 1 << 30
+
+// staleStaging is the age of a staging dir left by an interrupted install.
+const staleStaging = time.Hour
+
+// Doctor diagnoses GOHOME, the shell setup and the version selected for
+// dir. Suggested commands run the Executable of the Options.
+func (m *Manager) Doctor(dir string) (findings []Finding) {
+	version, source := m.Active(dir)
+
+	findings = append(findings, m.checkGoHome()...)
+	findings = append(findings, m.checkLeftovers()...)
+	findings = append(findings, m.checkShell()...)
+	findings = append(findings, m.checkPath(version, source)...)
+	findings = append(findings, m.checkInstalls()...)
+
+	return
+}
+
+func (m *Manager) checkGoHome() (findings []Finding) {
+	var home = m.home
+	if !files.IsDir(home) {
+		return append(findings, Finding{
+			Problem: fmt.Sprintf("GOHOME %s does not exist", home),
+			Suggest: "create it",
+			Fix:     func() error { return os.MkdirAll(home, 0755) },
+		})
+	}
+
+	if file, err := ioutil.TempFile(home, ".doctor-"); err != nil {
+		findings = append(findings, Finding{
+			Problem: fmt.Sprintf("GOHOME %s is not writable: %v", home, err),
+			Suggest: "change the owner of GOHOME or set GOHOME to a writable directory",
+		})
+	} else {
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	if free, err := utils.DiskFree(home); err != nil {
+		debug.Println("gvm: disk free error:", err.Error())
+	} else if free < minFree {
+		findings = append(findings, Finding{
+			Problem: fmt.Sprintf("only %s free in GOHOME %s, an install needs about %s", conf.Size(free), home, conf.Size(minFree)),
+			Suggest: fmt.Sprintf("free space, for example with: %s cache clean", m.executable),
+		})
+	}
+
+	return
+}
+
+func (m *Manager) checkLeftovers() (findings []Finding) {
+	var remove = func(filename string) func() error {
+		return func() error { return os.RemoveAll(filename) }
+	}
+
+	// GOHOME/go is the system installation when GOHOME is /usr/local, it
+	// is only removed if it is broken or marked as a staging dir
+	if dir := filepath.Join(m.home, "go"); files.IsDir(dir) {
+		var adopted bool
+		for _, a := range m.Adopted() {
+			if root, err := files.RealPath(dir); err == nil && a.Root == root {
+				adopted = true
+			}
+		}
+
+		version, err := rootGoVersion(dir)
+		switch {
+		case files.Exists(filepath.Join(dir, stagingMarker)) || err != nil:
+			findings = append(findings, Finding{
+				Problem: fmt.Sprintf("%s is left by an interrupted or old install", dir),
+				Suggest: "remove it",
+				Fix:     remove(dir),
+			})
+		case !adopted:
+			findings = append(findings, Finding{
+				Problem: fmt.Sprintf("%s is %s installed outside of gvm", dir, version),
+				Suggest: fmt.Sprintf("use it in place with: %s adopt %s", m.executable, dir),
+			})
+		}
+	}
+
+	entries, err := ioutil.ReadDir(m.home)
+	if err != nil {
+		debug.Println("gvm: read GOHOME error:", err.Error())
+	}
+	for _, entry := range entries {
+		var filename = filepath.Join(m.home, entry.Name())
+		switch {
+		case entry.IsDir() && strings.HasPrefix(entry.Name(), StagingPrefix):
+			if time.Since(entry.ModTime()) < staleStaging {
+				continue
+			}
+			findings = append(findings, Finding{
+				Problem: fmt.Sprintf("%s is left by an interrupted install", filename),
+				Suggest: "remove it",
+				Fix:     remove(filename),
+			})
+		case !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".tar.gz") || strings.HasSuffix(entry.Name(), ".zip") || strings.HasSuffix(entry.Name(), ".partial")):
+			findings = append(findings, Finding{
+				Problem: fmt.Sprintf("%s is an archive of an old install, downloads go to the archive cache", filename),
+				Suggest: "remove it",
+				Fix:     remove(filename),
+			})
+		}
+	}
+
+	archives, err := m.cache.List()
+	if err != nil {
+		debug.Println("gvm: cache list error:", err.Error())
+	}
+	for _, entry := range archives {
+		if !entry.Partial {
+			continue
+		}
+		var entry = entry
+		findings = append(findings, Finding{
+			Problem: fmt.Sprintf("%s is a partial download in the archive cache", entry.Path()),
+			Suggest: "remove it, or resume it by installing the version",
+			Fix:     func() error { return cache.Remove(entry) },
+		})
+	}
+
+	return
+}
+
+// checkShell checks that the rc file of the login shell loads ~/.gvmrc or
+// the shell hook, so set applies to new shells.
+func (m *Manager) checkShell() (findings []Finding) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	// no login shell, like in CI and containers
+	if os.Getenv("SHELL") == "" {
+		return
+	}
+
+	var sh = filepath.Base(os.Getenv("SHELL"))
+	var rc string
+	switch sh {
+	case "bash":
+		rc = paths.Home(".bashrc")
+	case "zsh":
+		if rc = os.Getenv("ZDOTDIR"); rc == "" {
+			rc = paths.Home()
+		}
+		rc = filepath.Join(rc, ".zshrc")
+	case "fish":
+		rc = paths.Home(".config", "fish", "config.fish")
+	default:
+		return append(findings, Finding{
+			Problem: fmt.Sprintf("login shell %q is not supported", os.Getenv("SHELL")),
+			Suggest: fmt.Sprintf("load %s in the rc file of your shell", paths.GvmRunCom()),
+		})
+	}
+
+	data, err := ioutil.ReadFile(rc)
+	if err != nil && !os.IsNotExist(err) {
+		return append(findings, Finding{
+			Problem: fmt.Sprintf("%s: %v", rc, err),
+			Suggest: "fix its permissions",
+		})
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.Contains(line, paths.GvmRunCom()) || strings.Contains(line, "~/.gvmrc") || strings.Contains(line, "hook "+sh) {
+			return
+		}
+	}
+
+	var f = Finding{
+		Problem: fmt.Sprintf("%s does not load %s, set has no effect in new %s shells", rc, paths.GvmRunCom(), sh),
+		Suggest: fmt.Sprintf("add 'source %s' to %s", paths.GvmRunCom(), rc),
+	}
+	if sh == "fish" {
+		f.Suggest = fmt.Sprintf("add '%s hook fish | source' to %s", m.executable, rc)
+	} else {
+		f.Fix = func() error {
+			return files.AppendFile(rc, []byte(fmt.Sprintf("\nsource %s\n", paths.GvmRunCom())), 0644)
+		}
+	}
+
+	return append(findings, f)
+}
+
+// checkPath checks that go on PATH, GOROOT and GOTOOLCHAIN run the selected
+// version.
+func (m *Manager) checkPath(version, source string) (findings []Finding) {
+	switch {
+	case version == "":
+		return append(findings, Finding{
+			Problem: "no go version selected",
+			Suggest: fmt.Sprintf("select one with: %s set <version>", m.executable),
+		})
+	case !m.Exists(version):
+		return append(findings, Finding{
+			Problem: fmt.Sprintf("%s selected by %s is not installed", version, source),
+			Suggest: fmt.Sprintf("install it with: %s install %s", m.executable, version),
+		})
+	}
+
+	var bin = filepath.Join(m.Root(version), "bin")
+	if found, err := exec.LookPath("go"); err != nil {
+		findings = append(findings, Finding{
+			Problem: "go is not on PATH",
+			Suggest: fmt.Sprintf("add %s or %s to PATH", bin, m.ShimsDir()),
+		})
+	} else {
+		dir, _ := files.RealPath(filepath.Dir(found))
+		want, _ := files.RealPath(bin)
+		shims, _ := files.RealPath(m.ShimsDir())
+		if dir != want && dir != shims {
+			findings = append(findings, Finding{
+				Problem: fmt.Sprintf("%s shadows %s selected by %s", found, version, source),
+				Suggest: fmt.Sprintf("put %s first in PATH, or use the shell hook: eval \"$(%s hook bash)\"", bin, m.executable),
+			})
+		}
+	}
+
+	if root := os.Getenv("GOROOT"); root != "" && filepath.Clean(root) != filepath.Clean(m.Root(version)) {
+		findings = append(findings, Finding{
+			Problem: fmt.Sprintf("GOROOT=%s is not the GOROOT of %s", root, version),
+			Suggest: fmt.Sprintf("unset GOROOT or set it to %s", m.Root(version)),
+		})
+	}
+
+	if toolchain := os.Getenv("GOTOOLCHAIN"); strings.HasPrefix(toolchain, "go") {
+		findings = append(findings, Finding{
+			Problem: fmt.Sprintf("GOTOOLCHAIN=%s makes go switch away from %s", toolchain, version),
+			Suggest: "unset GOTOOLCHAIN or set it to local",
+		})
+	}
+
+	return
+}
+
+// checkInstalls reports the versions whose bin/go version fails.
+func (m *Manager) checkInstalls() (findings []Finding) {
+	entries, err := ioutil.ReadDir(m.home)
+	if err != nil {
+		debug.Println("gvm: read GOHOME error:", err.Error())
+	}
+
+	for _, entry := range entries {
+		var version = entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(version, "go") || version == "go" {
+			continue
+		}
+		if _, err := golang.ParseVersion(version); err != nil && !IsBuild(version) {
+			continue
+		}
+
+		var root = filepath.Join(m.home, version)
+		if err := Verify(root, version); err != nil {
+			findings = append(findings, Finding{
+				Problem: fmt.Sprintf("%s is broken: %v", version, err),
+				Suggest: fmt.Sprintf("remove it and install it again with: %s install %s", m.executable, version),
+				Fix:     func() error { return os.RemoveAll(root) },
+			})
+		}
+	}
+
+	for version, a := range m.Adopted() {
+		if files.IsDir(filepath.Join(m.home, version)) {
+			continue
+		}
+		if err := Verify(a.Root, version); err != nil {
+			var version = version
+			findings = append(findings, Finding{
+				Problem: fmt.Sprintf("adopted %s at %s is broken: %v", version, a.Root, err),
+				Suggest: fmt.Sprintf("unregister it with: %s uninstall %s", m.executable, version),
+				Fix:     func() error { return m.Unadopt(version) },
+			})
+		}
+	}
+
+	return
+}
@@ -0,0 +1,536 @@
+// Package gvm manages the go versions of a GOHOME: it installs releases,
+// source and development builds, adopts installations outside of GOHOME
+// and selects the version of a shell, a project or a command.
+//
+// A Manager is configured by its Options, managers of different GOHOMEs or
+// mirrors can be used side by side. Only the network timeout is shared, it
+// is conf.Timeout.
+package gvm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+
+	"github.com/zooyer/gvm/interval/cache"
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/files"
+	"github.com/zooyer/gvm/interval/golang"
+	"github.com/zooyer/gvm/interval/paths"
+	"github.com/zooyer/gvm/interval/project"
+	"github.com/zooyer/gvm/interval/utils"
+)
+
+// VersionEnv selects the version of the current shell session.
+const VersionEnv = "GVM_VERSION"
+
+// defaults of the Options.
+const (
+	defaultGitRemote = "https://go.googlesource.com/go"
+	defaultIndexTTL  = 24 * time.Hour
+	defaultRetry     = 3
+)
+
+// Options configure a Manager.
+type Options struct {
+	// GoHome is the directory of the installed versions, default
+	// golang.DefaultGoHome().
+	GoHome string
+
+	// CacheDir is the archive cache directory, default GoHome/cache.
+	CacheDir string
+
+	// Mirrors are the mirror urls of the version index and the archives in
+	// fallback order, like https://golang.google.cn/dl/, default the
+	// official downloads.
+	Mirrors []string
+
+	// Offline uses only the cached version index and the installed
+	// versions.
+	Offline bool
+
+	// IndexFile caches the version index, default GoHome/versions.json.
+	IndexFile string
+
+	// IndexTTL is how long the cached version index is used before it is
+	// fetched again, default a day.
+	IndexTTL time.Duration
+
+	// Retry is the number of times a failed download is retried, default
+	// 3, negative for none.
+	Retry int
+
+	// GitRemote is the go repository built by tip, default
+	// https://go.googlesource.com/go.
+	GitRemote string
+
+	// GoToolchain is the GOTOOLCHAIN set by Env, empty keeps the
+	// environment.
+	GoToolchain string
+
+	// Output receives the progress of installs and downloads, default
+	// discarded.
+	Output io.Writer
+
+	// Executable is the gvm binary run by the shims and by the commands
+	// Doctor suggests, default the binary of the current process.
+	Executable string
+}
+
+// Manager manages the go versions of a GOHOME. It is safe for concurrent
+// use, concurrent installs of one version wait for the first one.
+type Manager struct {
+	home        string
+	cache       cache.Cache
+	index       *golang.Index
+	downloader  utils.Downloader
+	gitRemote   string
+	goToolchain string
+	out         io.Writer
+	executable  string
+
+	mu         sync.Mutex
+	adoptions  map[string]Adoption
+	installing map[string]*sync.Mutex
+}
+
+// New returns a Manager configured by opts.
+func New(opts Options) *Manager {
+	var m = &Manager{
+		home:        opts.GoHome,
+		cache:       cache.Cache{Dir: opts.CacheDir},
+		gitRemote:   opts.GitRemote,
+		goToolchain: opts.GoToolchain,
+		out:         opts.Output,
+		executable:  opts.Executable,
+	}
+
+	if m.home == "" {
+		m.home = golang.DefaultGoHome()
+	}
+	if m.cache.Dir == "" {
+		m.cache.Dir = filepath.Join(m.home, "cache")
+	}
+	if m.gitRemote == "" {
+		m.gitRemote = defaultGitRemote
+	}
+	if m.out == nil {
+		m.out = ioutil.Discard
+	}
+	if m.executable == "" {
+		m.executable = paths.AbsThisFile()
+	}
+
+	m.index = &golang.Index{
+		File:    opts.IndexFile,
+		Mirrors: opts.Mirrors,
+		Offline: opts.Offline,
+		TTL:     opts.IndexTTL,
+	}
+	if m.index.File == "" {
+		m.index.File = filepath.Join(m.home, "versions.json")
+	}
+	if m.index.TTL == 0 {
+		m.index.TTL = defaultIndexTTL
+	}
+
+	m.downloader = utils.Downloader{Retry: opts.Retry, Output: m.out}
+	switch {
+	case opts.Retry == 0:
+		m.downloader.Retry = defaultRetry
+	case opts.Retry < 0:
+		m.downloader.Retry = 0
+	}
+
+	return m
+}
+
+// Home returns the GOHOME of m.
+func (m *Manager) Home() string {
+	return m.home
+}
+
+// Refresh makes the next lookup of the version index fetch it, ignoring
+// the cached index.
+func (m *Manager) Refresh() {
+	m.index.Refresh()
+}
+
+// Available returns the versions with an archive for the current os and
+// arch in the version index, from oldest to newest.
+func (m *Manager) Available() []string {
+	return m.index.Available()
+}
+
+// bar returns bar, or a new bar drawn on the output if bar is nil, and the
+// func finishing a new bar.
+func (m *Manager) bar(bar *pb.ProgressBar) (*pb.ProgressBar, func()) {
+	if bar != nil {
+		return bar, func() {}
+	}
+	bar = pb.Default.New(0).SetWriter(m.out).Start()
+	return bar, func() { bar.Finish() }
+}
+
+// Verify checks that bin/go of root reports version for the current os
+// and arch.
+func Verify(root, version string) error {
+	binary := filepath.Join(root, "bin", "go")
+	out, err := utils.Command(binary, "version")
+	if err != nil {
+		return fmt.Errorf("%s version: %w", binary, err)
+	}
+	if IsBuild(version) {
+		// a development build reports its own version
+		if platform := fmt.Sprintf(" %s/%s", runtime.GOOS, runtime.GOARCH); !strings.HasPrefix(out, "go version ") || !strings.Contains(out, platform) {
+			return fmt.Errorf("%s version: %q, expected a%s go", binary, strings.TrimSpace(out), platform)
+		}
+		return nil
+	}
+	if expected := fmt.Sprintf("go version %s %s/%s", version, runtime.GOOS, runtime.GOARCH); !strings.HasPrefix(out, expected) {
+		return fmt.Errorf("%s version: %q, expected %q", binary, strings.TrimSpace(out), expected)
+	}
+	return nil
+}
+
+// Exists reports whether version is installed and works.
+func (m *Manager) Exists(version string) bool {
+	return Verify(m.Root(version), version) == nil
+}
+
+// Root returns the GOROOT of version, in GOHOME or adopted.
+func (m *Manager) Root(version string) string {
+	var root = filepath.Join(m.home, version)
+	if a, exists := m.adoption(version); exists && !files.IsDir(root) {
+		return a.Root
+	}
+	return root
+}
+
+// RootVersion returns the version of the GOROOT root, if it is in GOHOME
+// or adopted.
+func (m *Manager) RootVersion(root string) string {
+	root = filepath.Clean(strings.TrimSpace(root))
+	if dir, version := filepath.Split(root); filepath.Clean(dir) == filepath.Clean(m.home) {
+		return version
+	}
+
+	for version, a := range m.Adopted() {
+		if filepath.Clean(a.Root) == root {
+			return version
+		}
+	}
+
+	return ""
+}
+
+// isVersionBin reports whether dir is the bin dir of a version in GOHOME
+// or adopted.
+func (m *Manager) isVersionBin(dir string) bool {
+	dir = filepath.Clean(dir)
+	return filepath.Base(dir) == "bin" && m.RootVersion(filepath.Dir(dir)) != ""
+}
+
+// Path returns the PATH list path with the bin dir of version first and
+// the bin dirs of other versions removed. Without version, only the bin
+// dirs are removed.
+func (m *Manager) Path(path, version string) string {
+	var bin string
+	var list []string
+	if version != "" {
+		bin = filepath.Join(m.Root(version), "bin")
+		list = append(list, bin)
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" || dir == bin || m.isVersionBin(dir) {
+			continue
+		}
+		list = append(list, dir)
+	}
+
+	return strings.Join(list, string(os.PathListSeparator))
+}
+
+// Installed returns the versions installed in GOHOME and the adopted ones.
+func (m *Manager) Installed() (versions []string) {
+	var seen = make(map[string]bool)
+
+	entries, err := ioutil.ReadDir(m.home)
+	if err != nil {
+		debug.Println("gvm: read GOHOME error:", err.Error())
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "go") && m.Exists(entry.Name()) {
+			seen[entry.Name()] = true
+			versions = append(versions, entry.Name())
+		}
+	}
+
+	for version := range m.Adopted() {
+		if !seen[version] && m.Exists(version) {
+			versions = append(versions, version)
+		}
+	}
+
+	return
+}
+
+// Resolve resolves a version query like "1.21" or "latest" to an exact
+// version, against the installed and remote versions, only the installed
+// ones when offline. tip is the newest installed tip build.
+func (m *Manager) Resolve(query string) (version string, err error) {
+	if version, ok := m.ResolveBuild(query); ok {
+		return version, nil
+	}
+	if query == "tip" {
+		return "", fmt.Errorf("no tip build installed")
+	}
+
+	c, err := golang.ParseConstraint(query)
+	if err != nil {
+		return
+	}
+
+	var versions = m.Installed()
+	if !c.IsExact() && !m.index.Offline {
+		versions = append(versions, m.index.Available()...)
+	}
+
+	return golang.Resolve(query, versions)
+}
+
+// Global returns the version selected by Activate, from GOROOT of the
+// persistent environment.
+func (m *Manager) Global() string {
+	root, err := utils.GetAbsEnv("GOROOT")
+	if err != nil || root == "" {
+		return ""
+	}
+
+	return m.RootVersion(root)
+}
+
+// Active returns the version selected for dir and what selected it: the
+// GVM_VERSION environment variable, the nearest project file or the
+// global version. Queries are resolved against the installed versions.
+func (m *Manager) Active(dir string) (version, source string) {
+	var query string
+	if query = os.Getenv(VersionEnv); query != "" {
+		source = VersionEnv + " environment variable"
+	} else if pin, ok := project.Find(dir); ok {
+		query, source = pin.Version, pin.File
+	} else if query = m.Global(); query != "" {
+		return query, "gvm set"
+	} else {
+		return "", ""
+	}
+
+	if version, ok := m.ResolveBuild(query); ok {
+		return version, source
+	}
+
+	// exact versions need no lookup of the installed ones
+	var resolve = func(query string) (string, error) {
+		return golang.Resolve(query, nil)
+	}
+	if c, err := golang.ParseConstraint(query); err == nil && !c.IsExact() {
+		resolve = m.resolveInstalled
+	}
+
+	version, err := resolve(query)
+	if err != nil {
+		return query, source
+	}
+
+	return version, source
+}
+
+// resolveInstalled resolves query against the installed versions. Shims
+// resolve on every go command, so the versions are listed by name and only
+// the resolved one is verified, a broken one is skipped.
+func (m *Manager) resolveInstalled(query string) (version string, err error) {
+	var versions []string
+	entries, err := ioutil.ReadDir(m.home)
+	if err != nil {
+		debug.Println("gvm: read GOHOME error:", err.Error())
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "go") {
+			versions = append(versions, entry.Name())
+		}
+	}
+	for version := range m.Adopted() {
+		versions = append(versions, version)
+	}
+
+	for {
+		if version, err = golang.Resolve(query, versions); err != nil || m.Exists(version) {
+			return
+		}
+
+		var rest []string
+		for _, v := range versions {
+			if v != version {
+				rest = append(rest, v)
+			}
+		}
+		versions = rest
+	}
+}
+
+// Activate makes the installed version the global version of new shells.
+func (m *Manager) Activate(version string) (err error) {
+	if !m.Exists(version) {
+		return fmt.Errorf("%s is not installed", version)
+	}
+
+	if err = utils.SetAbsEnv("GOROOT", m.Root(version)); err != nil {
+		return
+	}
+
+	// the bin dirs of the other versions, installed or adopted, are removed
+	path, err := utils.GetAbsEnv("PATH")
+	if err != nil {
+		return
+	}
+	if err = utils.SetAbsEnv("PATH", m.Path(path, version)); err != nil {
+		return
+	}
+
+	return m.MarkUsed(version)
+}
+
+// Env returns the environment of the current process switched to version.
+func (m *Manager) Env(version string) []string {
+	var env = map[string]string{
+		"GOROOT": m.Root(version),
+		"PATH":   m.Path(os.Getenv("PATH"), version),
+	}
+	if m.goToolchain != "" {
+		env["GOTOOLCHAIN"] = m.goToolchain
+	}
+
+	return Override(env)
+}
+
+// Override returns the environment of the current process with the keys
+// of env replaced, an empty value removes the key.
+func Override(env map[string]string) []string {
+	var environ []string
+	for _, kv := range os.Environ() {
+		if index := strings.Index(kv, "="); index > 0 {
+			if _, exists := env[kv[:index]]; exists {
+				continue
+			}
+		}
+		environ = append(environ, kv)
+	}
+
+	var keys []string
+	for key, val := range env {
+		if val != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		environ = append(environ, key+"="+env[key])
+	}
+
+	return environ
+}
+
+// Version describes a go version. Size and Sha256 are of the archive for
+// the current os and arch.
+type Version struct {
+	Version   string `json:"version"`
+	Installed bool   `json:"installed"`
+	Active    bool   `json:"active"`
+	System    bool   `json:"system"`
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Sha256    string `json:"sha256"`
+}
+
+func (m *Manager) describe(version, active string) Version {
+	var v = Version{Version: version, Active: version == active}
+	if v.Installed = m.Exists(version); v.Installed {
+		v.Path = m.Root(version)
+		if a, exists := m.adoption(version); exists && v.Path == a.Root {
+			v.System = true
+		}
+	}
+	if archive, err := m.index.Find(version); err == nil {
+		v.Size, v.Sha256 = archive.Size, archive.Sha256
+	}
+
+	return v
+}
+
+// Describe returns the description of version, active in the working
+// directory or not.
+func (m *Manager) Describe(version string) Version {
+	active, _ := m.Active(".")
+	return m.describe(version, active)
+}
+
+// List returns the remote and installed versions, from oldest to newest.
+func (m *Manager) List() []Version {
+	var versions = m.index.List()
+	var listed = make(map[string]bool)
+	for _, version := range versions {
+		listed[version] = true
+	}
+	for _, version := range m.Installed() {
+		if !listed[version] {
+			versions = append(versions, version)
+		}
+	}
+	golang.Sort(versions)
+
+	var active, _ = m.Active(".")
+	var list = make([]Version, 0, len(versions))
+	for _, version := range versions {
+		list = append(list, m.describe(version, active))
+	}
+
+	return list
+}
+
+// Uninstall removes version from GOHOME, or unregisters it if it is
+// adopted, and returns "uninstalled" or "unregistered".
+func (m *Manager) Uninstall(version string) (status string, err error) {
+	if a, exists := m.adoption(version); exists && m.Root(version) == a.Root {
+		return "unregistered", m.Unadopt(version)
+	}
+
+	if m.Exists(version) {
+		if err = os.RemoveAll(filepath.Join(m.home, version)); err != nil {
+			return
+		}
+		_ = os.RemoveAll(filepath.Join(m.home, version+".tar.gz"))
+	}
+
+	if builds := m.Builds(); IsBuild(version) {
+		if _, exists := builds[version]; exists {
+			delete(builds, version)
+			if err = files.WriteJSON(m.buildsFile(), builds, 0644); err != nil {
+				return
+			}
+		}
+	}
+
+	return "uninstalled", nil
+}
@@ -0,0 +1,797 @@
+package gvm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zooyer/gvm/interval/files"
+	"github.com/zooyer/gvm/interval/golang"
+	"github.com/zooyer/gvm/interval/utils"
+)
+
+// fakeGo writes a go tree at root whose bin/go reports version.
+func fakeGo(t *testing.T, root, version string) {
+	if err := os.MkdirAll(filepath.Join(root, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var script = fmt.Sprintf("#!/bin/sh\necho \"go version %s %s/%s\"\n", version, runtime.GOOS, runtime.GOARCH)
+	if err := ioutil.WriteFile(filepath.Join(root, "bin", "go"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeRelease returns a tar.gz of a go tree whose bin/go reports version,
+// and its sha256.
+func fakeRelease(t *testing.T, version string) ([]byte, string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	var script = fmt.Sprintf("#!/bin/sh\necho \"go version %s %s/%s\"\n", version, runtime.GOOS, runtime.GOARCH)
+	for _, f := range []struct {
+		name, body string
+		mode       int64
+	}{
+		{"go/", "", 0755},
+		{"go/bin/", "", 0755},
+		{"go/bin/go", script, 0755},
+		{"go/VERSION", version + "\n", 0644},
+	} {
+		var header = &tar.Header{Name: f.name, Mode: f.mode, Size: int64(len(f.body)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(f.name, "/") {
+			header.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var sum = sha256.Sum256(buf.Bytes())
+	return buf.Bytes(), hex.EncodeToString(sum[:])
+}
+
+// mirror serves the version index and the archives of releases, by
+// version. The index lists the published checksum of an archive, or its
+// sha256 if none is published. Archive downloads are counted in requests.
+type mirror struct {
+	*httptest.Server
+	releases  map[string][]byte
+	checksums map[string]string
+	requests  int32
+}
+
+func serveMirror(t *testing.T, versions ...string) *mirror {
+	var m = &mirror{releases: make(map[string][]byte), checksums: make(map[string]string)}
+	for _, version := range versions {
+		m.releases[version], m.checksums[version] = fakeRelease(t, version)
+	}
+
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery == "" {
+			for version, data := range m.releases {
+				if strings.HasSuffix(r.URL.Path, "/"+golang.Filename(version)) {
+					atomic.AddInt32(&m.requests, 1)
+					http.ServeContent(w, r, golang.Filename(version), time.Time{}, bytes.NewReader(data))
+					return
+				}
+			}
+			http.NotFound(w, r)
+			return
+		}
+
+		var feed []map[string]interface{}
+		for version := range m.releases {
+			feed = append(feed, map[string]interface{}{
+				"version": version,
+				"stable":  true,
+				"files": []map[string]interface{}{{
+					"filename": golang.Filename(version),
+					"os":       runtime.GOOS,
+					"arch":     runtime.GOARCH,
+					"version":  version,
+					"sha256":   m.checksums[version],
+					"size":     len(m.releases[version]),
+					"kind":     "archive",
+				}},
+			})
+		}
+		json.NewEncoder(w).Encode(feed)
+	}))
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+// manager returns a manager of a new GOHOME installing from m.
+func (m *mirror) manager(t *testing.T) *Manager {
+	return New(Options{GoHome: t.TempDir(), Mirrors: []string{m.URL + "/dl/"}, Retry: -1})
+}
+
+func TestInstall(t *testing.T) {
+	if !golang.CanStream() {
+		t.Skip("fake releases are tar.gz archives")
+	}
+
+	var mirror = serveMirror(t, "go1.21.5")
+	var m = mirror.manager(t)
+
+	if status, err := m.Install(context.Background(), "go1.21.5", InstallOptions{}); err != nil || status != "installed" {
+		t.Fatalf("install: got %s, %v", status, err)
+	}
+	if !m.Exists("go1.21.5") || m.Root("go1.21.5") != filepath.Join(m.Home(), "go1.21.5") {
+		t.Error("go1.21.5 not installed")
+	}
+	if status, err := m.Install(context.Background(), "go1.21.5", InstallOptions{}); err != nil || status != "already installed" {
+		t.Errorf("install again: got %s, %v", status, err)
+	}
+	if _, err := m.Install(context.Background(), "go1.21.6", InstallOptions{}); err == nil {
+		t.Error("installed an unknown version")
+	}
+
+	var results = m.InstallVersions(context.Background(), []string{"go1.21.5", "go1.21.6"}, 2, InstallOptions{})
+	if len(results) != 2 || results[0].Status != "already installed" || results[1].Err == nil {
+		t.Errorf("install versions: got %+v", results)
+	}
+}
+
+func TestManager(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go trees are shell scripts")
+	}
+	var home = t.TempDir()
+	var m = New(Options{GoHome: home, GoToolchain: "local", Offline: true})
+	if m.CacheDir() != filepath.Join(home, "cache") {
+		t.Errorf("cache dir: got %s", m.CacheDir())
+	}
+
+	fakeGo(t, filepath.Join(home, "go1.21.1"), "go1.21.1")
+	fakeGo(t, filepath.Join(home, "go1.21.3"), "go1.21.3")
+	fakeGo(t, filepath.Join(home, "go1.22.0"), "go1.20.0")
+
+	var system = filepath.Join(t.TempDir(), "go")
+	fakeGo(t, system, "go1.20.5")
+	if version, status, err := m.Adopt(system); err != nil || version != "go1.20.5" || status != "adopted" {
+		t.Fatalf("adopt: got %s %s, %v", version, status, err)
+	}
+
+	var installed = strings.Join(m.Installed(), " ")
+	if installed != "go1.21.1 go1.21.3 go1.20.5" {
+		t.Errorf("installed: got %s", installed)
+	}
+
+	if version, err := m.Resolve("1.21"); err != nil || version != "go1.21.3" {
+		t.Errorf("resolve: got %s, %v", version, err)
+	}
+
+	root, _ := filepath.EvalSymlinks(system)
+	if m.Root("go1.20.5") != root || m.RootVersion(root) != "go1.20.5" {
+		t.Errorf("adopted root: got %s", m.Root("go1.20.5"))
+	}
+
+	var path = strings.Join([]string{filepath.Join(home, "go1.21.1", "bin"), "/usr/bin", filepath.Join(root, "bin")}, string(os.PathListSeparator))
+	if got, want := m.Path(path, "go1.21.3"), filepath.Join(home, "go1.21.3", "bin")+string(os.PathListSeparator)+"/usr/bin"; got != want {
+		t.Errorf("path: got %s, want %s", got, want)
+	}
+
+	var env = strings.Join(m.Env("go1.21.3"), "\n")
+	if !strings.Contains(env, "GOROOT="+filepath.Join(home, "go1.21.3")+"\n") || !strings.Contains(env, "GOTOOLCHAIN=local") {
+		t.Errorf("env: got %s", env)
+	}
+
+	if status, err := m.Uninstall("go1.20.5"); err != nil || status != "unregistered" {
+		t.Errorf("uninstall adopted: got %s, %v", status, err)
+	}
+	if _, err := os.Stat(system); err != nil {
+		t.Errorf("adopted installation removed: %v", err)
+	}
+	if status, err := m.Uninstall("go1.21.1"); err != nil || status != "uninstalled" || m.Exists("go1.21.1") {
+		t.Errorf("uninstall: got %s, %v", status, err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var m = New(Options{GoHome: t.TempDir()})
+
+	if err := m.MarkUsed("go1.21.3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := m.LastUsed()["go1.21.3"]; !exists {
+		t.Error("usage not recorded")
+	}
+
+	var a, b = filepath.Join(m.Home(), "a", "go.mod"), filepath.Join(m.Home(), "b", ".go-version")
+	for _, filename := range []string{a, b, a} {
+		if err := m.Remember(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Forget(a); err != nil {
+		t.Fatal(err)
+	}
+	if projects := m.Projects(); len(projects) != 1 || projects[0] != b {
+		t.Errorf("projects: got %v", projects)
+	}
+}
+
+func TestInstallOverBrokenAdoption(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go trees are shell scripts")
+	}
+
+	var home = t.TempDir()
+	var m = New(Options{GoHome: home})
+
+	var system = filepath.Join(t.TempDir(), "go")
+	fakeGo(t, system, "go1.20.5")
+	if _, _, err := m.Adopt(system); err != nil {
+		t.Fatal(err)
+	}
+
+	// the adopted installation stops reporting its version
+	fakeGo(t, system, "go1.21.0")
+	if err := ioutil.WriteFile(filepath.Join(system, "README.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var source = filepath.Join(t.TempDir(), "go")
+	fakeGo(t, source, "go1.20.5")
+	if err := ioutil.WriteFile(filepath.Join(source, "VERSION"), []byte("go1.20.5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if version, status, err := m.InstallFrom(context.Background(), source, ""); err != nil || version != "go1.20.5" || status != "installed" {
+		t.Fatalf("install: got %s %s, %v", version, status, err)
+	}
+
+	if !files.IsFile(filepath.Join(system, "README.md")) {
+		t.Error("adopted installation replaced")
+	}
+	if root := m.Root("go1.20.5"); root != filepath.Join(home, "go1.20.5") || !m.Exists("go1.20.5") {
+		t.Errorf("root: got %s", root)
+	}
+}
+
+func TestAdoptGoHome(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go trees are shell scripts")
+	}
+
+	// GOHOME is a go installation, like the default /usr/local/go
+	var home = t.TempDir()
+	var m = New(Options{GoHome: home})
+	fakeGo(t, home, "go1.20.5")
+	fakeGo(t, filepath.Join(home, "go1.21.3"), "go1.21.3")
+
+	if version, status, err := m.Adopt(home); err != nil || version != "go1.20.5" || status != "adopted" {
+		t.Errorf("adopt GOHOME: got %s %s, %v", version, status, err)
+	}
+	if _, _, err := m.Adopt(filepath.Join(home, "go1.21.3")); err == nil {
+		t.Error("adopted an installed version")
+	}
+}
+
+func TestInstallSourceCheckout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go trees are shell scripts")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	var home = t.TempDir()
+	var m = New(Options{GoHome: home})
+	fakeGo(t, filepath.Join(home, "go1.20.5"), "go1.20.5")
+
+	// a fork checkout: no VERSION file, make.bash writes a devel bin/go
+	var checkout = t.TempDir()
+	var sources = map[string]string{
+		"src/internal/goversion/goversion.go": "package goversion\n\nconst Version = 21\n",
+		"src/make.bash":                       fmt.Sprintf("#!/bin/sh\nmkdir -p ../bin\nprintf '#!/bin/sh\\necho \"go version devel %s/%s\"\\n' > ../bin/go\nchmod +x ../bin/go\n", runtime.GOOS, runtime.GOARCH),
+	}
+	for name, content := range sources {
+		var filename = filepath.Join(checkout, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=gvm", "-c", "user.email=gvm@localhost", "commit", "--quiet", "-m", "fork"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = checkout
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	version, status, err := m.InstallSource(context.Background(), checkout, InstallOptions{})
+	if err != nil || !strings.HasPrefix(version, gitPrefix) || status != "installed" {
+		t.Fatalf("install: got %s %s, %v", version, status, err)
+	}
+	if !m.Exists(version) {
+		t.Errorf("%s not installed", version)
+	}
+	if build, exists := m.Builds()[version]; !exists || build.URL != checkout {
+		t.Errorf("build: got %+v", build)
+	}
+
+	if _, _, err = m.InstallSource(context.Background(), t.TempDir(), InstallOptions{}); err == nil {
+		t.Error("installed a dir without VERSION file or git checkout")
+	}
+}
+
+func TestMirrors(t *testing.T) {
+	var serve = func(version string) *httptest.Server {
+		var feed = fmt.Sprintf(`[{"version": %q, "stable": true, "files": [{"filename": "%s.%s-%s.tar.gz", "os": %q, "arch": %q, "version": %q, "sha256": "00", "kind": "archive"}]}]`,
+			version, version, runtime.GOOS, runtime.GOARCH, runtime.GOOS, runtime.GOARCH, version)
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(feed))
+		}))
+	}
+	var a, b = serve("go1.21.5"), serve("go1.22.0")
+	defer a.Close()
+	defer b.Close()
+
+	var ma = New(Options{GoHome: t.TempDir(), Mirrors: []string{a.URL + "/dl/"}})
+	var mb = New(Options{GoHome: t.TempDir(), Mirrors: []string{b.URL + "/dl/"}})
+	for m, expected := range map[*Manager]string{ma: "go1.21.5", mb: "go1.22.0"} {
+		if version, err := m.Resolve("latest"); err != nil || version != expected {
+			t.Errorf("resolve: got %s %v, expected %s", version, err, expected)
+		}
+		if !files.IsFile(filepath.Join(m.Home(), "versions.json")) {
+			t.Errorf("%s: index not cached", m.Home())
+		}
+	}
+
+	// offline, only the cached index is used
+	a.Close()
+	var offline = New(Options{GoHome: ma.Home(), Offline: true})
+	if versions := offline.Available(); len(versions) != 1 || versions[0] != "go1.21.5" {
+		t.Errorf("offline: got %v", versions)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go trees are shell scripts")
+	}
+
+	var home = t.TempDir()
+	var m = New(Options{GoHome: home, Offline: true})
+	for _, version := range []string{"go1.20.1", "go1.20.2", "go1.20.3", "go1.21.0"} {
+		fakeGo(t, filepath.Join(home, version), version)
+	}
+
+	var dir = t.TempDir()
+	var pin = filepath.Join(dir, ".go-version")
+	if err := ioutil.WriteFile(pin, []byte("go1.20.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var gone = filepath.Join(t.TempDir(), ".go-version")
+	for _, filename := range []string{pin, gone} {
+		if err := m.Remember(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var statuses = func(pruned []Pruned) string {
+		var list []string
+		for _, p := range pruned {
+			if p.Err != nil {
+				t.Errorf("%s: %v", p.Version, p.Err)
+			}
+			list = append(list, p.Version+" "+p.Status)
+		}
+		return strings.Join(list, ", ")
+	}
+
+	var opts = PruneOptions{Dir: t.TempDir(), KeepPatches: 1, DryRun: true}
+	var pruned = m.Prune(opts)
+	if got, want := statuses(pruned), "go1.20.1 kept, go1.20.2 would be removed, go1.20.3 kept, go1.21.0 kept"; got != want {
+		t.Errorf("dry run: got %s, want %s", got, want)
+	}
+	if !m.Exists("go1.20.2") || len(m.Projects()) != 2 {
+		t.Error("dry run changed GOHOME")
+	}
+
+	opts.DryRun = false
+	pruned = m.Prune(opts)
+	if got, want := statuses(pruned), "go1.20.1 kept, go1.20.2 removed, go1.20.3 kept, go1.21.0 kept"; got != want {
+		t.Errorf("prune: got %s, want %s", got, want)
+	}
+	if pruned[1].Size == 0 || files.Exists(filepath.Join(home, "go1.20.2")) {
+		t.Errorf("go1.20.2 not removed, size %d", pruned[1].Size)
+	}
+	if projects := m.Projects(); len(projects) != 1 || projects[0] != pin {
+		t.Errorf("projects: got %v", projects)
+	}
+}
+
+func TestRehash(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go trees are shell scripts")
+	}
+
+	var home = t.TempDir()
+	var m = New(Options{GoHome: home, Executable: "/opt/gvm"})
+	fakeGo(t, filepath.Join(home, "go1.21.0"), "go1.21.0")
+	if err := m.RefreshShims(); err != nil || files.Exists(m.ShimsDir()) {
+		t.Fatalf("refresh without shims: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(home, "go1.21.0", "bin", "gofmt"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(m.ShimsDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(m.ShimsDir(), "vet"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tools, err := m.Rehash()
+	if err != nil || strings.Join(tools, " ") != "go gofmt" {
+		t.Fatalf("rehash: got %v, %v", tools, err)
+	}
+	if files.Exists(filepath.Join(m.ShimsDir(), "vet")) {
+		t.Error("stale shim kept")
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(m.ShimsDir(), "go")); err != nil || !strings.Contains(string(data), "exec '/opt/gvm' shim 'go'") {
+		t.Errorf("go shim: %q, %v", data, err)
+	}
+}
+
+func TestDoctorLeftovers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go trees are shell scripts")
+	}
+
+	// GOHOME is /usr/local, GOHOME/go is the system installation
+	var home = t.TempDir()
+	var m = New(Options{GoHome: home})
+	var system = filepath.Join(home, "go")
+	fakeGo(t, system, "go1.20.5")
+
+	var findings = m.checkLeftovers()
+	if len(findings) != 1 || findings[0].Fix != nil || !strings.Contains(findings[0].Suggest, "adopt") {
+		t.Fatalf("working GOHOME/go: got %+v", findings)
+	}
+	if _, _, err := m.Adopt(system); err != nil {
+		t.Fatal(err)
+	}
+	if findings = m.checkLeftovers(); len(findings) != 0 {
+		t.Errorf("adopted GOHOME/go: got %+v", findings)
+	}
+
+	if err := os.Remove(filepath.Join(system, "bin", "go")); err != nil {
+		t.Fatal(err)
+	}
+	if findings = m.checkLeftovers(); len(findings) != 1 || findings[0].Fix == nil {
+		t.Errorf("broken GOHOME/go: got %+v", findings)
+	}
+}
+
+func TestDoctorNoShell(t *testing.T) {
+	defer os.Setenv("SHELL", os.Getenv("SHELL"))
+	os.Unsetenv("SHELL")
+
+	if findings := New(Options{GoHome: t.TempDir()}).checkShell(); len(findings) != 0 {
+		t.Errorf("no login shell: got %+v", findings)
+	}
+}
+
+func TestActivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the environment is persisted in the registry")
+	}
+
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", t.TempDir())
+	var rc = `export PATH="/opt/gvm:$PATH"` + "\n"
+	if err := ioutil.WriteFile(filepath.Join(os.Getenv("HOME"), ".gvmrc"), []byte(rc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var home = t.TempDir()
+	var m = New(Options{GoHome: home})
+	fakeGo(t, filepath.Join(home, "go1.21.5"), "go1.21.5")
+	var system = filepath.Join(t.TempDir(), "go")
+	fakeGo(t, system, "go1.19.3")
+	if _, _, err := m.Adopt(system); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []string{"go1.21.5", "go1.19.3", "go1.21.5", "go1.19.3"} {
+		if err := m.Activate(version); err != nil {
+			t.Fatal(err)
+		}
+
+		var root = m.Root(version)
+		var want = filepath.Join(root, "bin") + ":/opt/gvm:$PATH"
+		if path, err := utils.GetGvmEnv("PATH"); err != nil || path != want {
+			t.Errorf("%s: PATH %s, want %s", version, path, want)
+		}
+		if goroot, err := utils.GetGvmEnv("GOROOT"); err != nil || goroot != root {
+			t.Errorf("%s: GOROOT %s, want %s", version, goroot, root)
+		}
+		if m.Global() != version {
+			t.Errorf("%s: global %s", version, m.Global())
+		}
+	}
+}
+
+func TestAdoptConcurrent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go trees are shell scripts")
+	}
+
+	var m = New(Options{GoHome: t.TempDir()})
+	var system = filepath.Join(t.TempDir(), "go")
+	fakeGo(t, system, "go1.20.5")
+
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			m.Root("go1.20.5")
+			m.Installed()
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		if _, _, err := m.Adopt(system); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Unadopt("go1.20.5"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
+
+func TestActiveVerifiesOnlyResolved(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go trees are shell scripts")
+	}
+
+	// every bin/go run is logged
+	var home = t.TempDir()
+	var log = filepath.Join(t.TempDir(), "runs")
+	for _, version := range []string{"go1.20.5", "go1.21.1", "go1.21.3", "go1.21.4"} {
+		fakeGo(t, filepath.Join(home, version), version)
+		var script = fmt.Sprintf("#!/bin/sh\necho %s >> %s\necho \"go version %s %s/%s\"\n", version, log, version, runtime.GOOS, runtime.GOARCH)
+		if err := ioutil.WriteFile(filepath.Join(home, version, "bin", "go"), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the newest match is broken
+	if err := os.Remove(filepath.Join(home, "go1.21.4", "bin", "go")); err != nil {
+		t.Fatal(err)
+	}
+
+	var dir = t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, ".go-version"), []byte("1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var m = New(Options{GoHome: home})
+	if version, _ := m.Active(dir); version != "go1.21.3" {
+		t.Errorf("active: got %s", version)
+	}
+	if data, err := ioutil.ReadFile(log); err != nil || string(data) != "go1.21.3\n" {
+		t.Errorf("go runs: got %q, %v", data, err)
+	}
+}
+
+// brokenInstall writes a go tree of version in GOHOME without bin/go,
+// holding a marker file, and returns the marker.
+func brokenInstall(t *testing.T, m *Manager, version string) string {
+	var marker = filepath.Join(m.Home(), version, "marker")
+	if err := os.MkdirAll(filepath.Dir(marker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return marker
+}
+
+// checkNoStaging fails if a staging dir is left in GOHOME.
+func checkNoStaging(t *testing.T, m *Manager) {
+	matches, _ := filepath.Glob(filepath.Join(m.Home(), StagingPrefix+"*"))
+	if len(matches) > 0 {
+		t.Errorf("staging dirs left: %v", matches)
+	}
+}
+
+func TestInstallFailures(t *testing.T) {
+	if !golang.CanStream() {
+		t.Skip("fake releases are tar.gz archives")
+	}
+
+	var mirror = serveMirror(t, "go1.21.5", "go1.21.6")
+
+	// a published checksum which does not match the archive
+	mirror.checksums["go1.21.5"] = strings.Repeat("0", 64)
+	// an archive whose bin/go reports another version
+	mirror.releases["go1.21.6"], mirror.checksums["go1.21.6"] = fakeRelease(t, "go1.21.4")
+
+	for _, stream := range []bool{false, true} {
+		for _, version := range []string{"go1.21.5", "go1.21.6"} {
+			var m = mirror.manager(t)
+			var marker = brokenInstall(t, m, version)
+
+			if _, err := m.Install(context.Background(), version, InstallOptions{Stream: stream}); err == nil {
+				t.Errorf("%s stream %t: installed", version, stream)
+			}
+			if !files.IsFile(marker) {
+				t.Errorf("%s stream %t: previous install changed", version, stream)
+			}
+			checkNoStaging(t, m)
+		}
+	}
+}
+
+func TestInstallRollback(t *testing.T) {
+	if !golang.CanStream() {
+		t.Skip("fake releases are tar.gz archives")
+	}
+
+	var mirror = serveMirror(t, "go1.21.5")
+	var m = mirror.manager(t)
+	var marker = brokenInstall(t, m, "go1.21.5")
+	var target = filepath.Join(m.Home(), "go1.21.5")
+
+	// renaming the new tree into place fails
+	defer func() { rename = os.Rename }()
+	rename = func(from, to string) error {
+		if to == target && filepath.Base(from) == "go" {
+			return fmt.Errorf("rename %s: injected failure", from)
+		}
+		return os.Rename(from, to)
+	}
+
+	if _, err := m.Install(context.Background(), "go1.21.5", InstallOptions{}); err == nil || !strings.Contains(err.Error(), "injected") {
+		t.Fatalf("install: expected the rename error, got %v", err)
+	}
+	if !files.IsFile(marker) {
+		t.Error("previous install not restored")
+	}
+	checkNoStaging(t, m)
+
+	rename = os.Rename
+	if status, err := m.Install(context.Background(), "go1.21.5", InstallOptions{}); err != nil || status != "installed" || files.Exists(marker) {
+		t.Errorf("install over the broken one: got %s, %v", status, err)
+	}
+	checkNoStaging(t, m)
+}
+
+func TestInstallConcurrent(t *testing.T) {
+	if !golang.CanStream() {
+		t.Skip("fake releases are tar.gz archives")
+	}
+
+	var mirror = serveMirror(t, "go1.21.5", "go1.22.0")
+	var m = mirror.manager(t)
+
+	var versions = []string{"go1.21.5", "go1.21.5", "go1.22.0", "go1.21.5"}
+	var statuses = make(map[string]int)
+	for _, r := range m.InstallVersions(context.Background(), versions, len(versions), InstallOptions{}) {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Version, r.Err)
+		}
+		statuses[r.Version+" "+r.Status]++
+	}
+
+	if statuses["go1.21.5 installed"] != 1 || statuses["go1.21.5 already installed"] != 2 || statuses["go1.22.0 installed"] != 1 {
+		t.Errorf("statuses: got %v", statuses)
+	}
+	if n := atomic.LoadInt32(&mirror.requests); n != 2 {
+		t.Errorf("archive requests: got %d, expected 2", n)
+	}
+	if !m.Exists("go1.21.5") || !m.Exists("go1.22.0") {
+		t.Error("versions not installed")
+	}
+	checkNoStaging(t, m)
+}
+
+func TestInstallStream(t *testing.T) {
+	if !golang.CanStream() {
+		t.Skip("fake releases are tar.gz archives")
+	}
+
+	var mirror = serveMirror(t, "go1.21.5")
+	for _, keep := range []bool{false, true} {
+		var m = mirror.manager(t)
+		if status, err := m.Install(context.Background(), "go1.21.5", InstallOptions{Stream: true, KeepArchive: keep}); err != nil || status != "installed" {
+			t.Fatalf("keep %t: got %s, %v", keep, status, err)
+		}
+
+		archives, err := m.Archives()
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		if keep && (len(archives) != 1 || archives[0].Sha256 != mirror.checksums["go1.21.5"] || archives[0].Partial) {
+			t.Errorf("kept archive: got %+v", archives)
+		}
+		if !keep && len(archives) != 0 {
+			t.Errorf("archive left: got %+v", archives)
+		}
+
+		matches, _ := filepath.Glob(filepath.Join(m.Home(), "*.tar.gz*"))
+		if len(matches) > 0 {
+			t.Errorf("keep %t: archives in GOHOME: %v", keep, matches)
+		}
+		checkNoStaging(t, m)
+	}
+}
+
+func TestInstallCached(t *testing.T) {
+	if !golang.CanStream() {
+		t.Skip("fake releases are tar.gz archives")
+	}
+
+	var mirror = serveMirror(t, "go1.21.5")
+	var cache = t.TempDir()
+	var first = New(Options{GoHome: t.TempDir(), CacheDir: cache, Mirrors: []string{mirror.URL + "/dl/"}, Retry: -1})
+	if _, err := first.Install(context.Background(), "go1.21.5", InstallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// another GOHOME sharing the cache, offline with the cached index
+	var index = filepath.Join(first.Home(), "versions.json")
+	var second = New(Options{GoHome: t.TempDir(), CacheDir: cache, IndexFile: index, Offline: true})
+	for _, stream := range []bool{false, true} {
+		os.RemoveAll(filepath.Join(second.Home(), "go1.21.5"))
+		if status, err := second.Install(context.Background(), "go1.21.5", InstallOptions{Stream: stream}); err != nil || status != "installed" {
+			t.Errorf("stream %t: got %s, %v", stream, status, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&mirror.requests); n != 1 {
+		t.Errorf("archive requests: got %d, expected 1", n)
+	}
+}
+
+func TestEnvToolchain(t *testing.T) {
+	defer os.Setenv("GOTOOLCHAIN", os.Getenv("GOTOOLCHAIN"))
+	os.Setenv("GOTOOLCHAIN", "go1.22.0+auto")
+
+	var home = t.TempDir()
+	for toolchain, want := range map[string]string{"": "go1.22.0+auto", "local": "local"} {
+		var env = strings.Join(New(Options{GoHome: home, GoToolchain: toolchain}).Env("go1.21.3"), "\n") + "\n"
+		if !strings.Contains(env, "\nGOTOOLCHAIN="+want+"\n") || strings.Count(env, "GOTOOLCHAIN=") != 1 {
+			t.Errorf("GoToolchain %q: got %s", toolchain, env)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package gvm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/files"
+	"github.com/zooyer/gvm/interval/golang"
+	"github.com/zooyer/gvm/interval/utils"
+)
+
+// StagingPrefix names the staging dirs of installs in GOHOME, a staging dir
+// left behind marks an interrupted install.
+const StagingPrefix = ".staging-"
+
+// stagingMarker is written to a staging dir, describing the install.
+const stagingMarker = ".gvm-install"
+
+// InstallOptions configure an install.
+type InstallOptions struct {
+	// Stream unpacks the archive while downloading, without storing it.
+	Stream bool
+
+	// KeepArchive keeps a streamed archive in the archive cache.
+	KeepArchive bool
+
+	// Bootstrap is the version or GOROOT building a source or development
+	// build, default the oldest installed version able to.
+	Bootstrap string
+}
+
+// rename renames an installed tree into place, tests make it fail.
+var rename = os.Rename
+
+// stage creates a staging dir for version in GOHOME, on the same file
+// system so the installed tree can be renamed into place.
+func (m *Manager) stage(version string) (dir string, err error) {
+	if err = os.MkdirAll(m.home, 0755); err != nil {
+		return
+	}
+
+	if dir, err = ioutil.TempDir(m.home, StagingPrefix+version+"-"); err != nil {
+		return
+	}
+
+	marker := fmt.Sprintf("version: %s\npid: %d\ntime: %s\n", version, os.Getpid(), time.Now().Format(time.RFC3339))
+	if err = ioutil.WriteFile(filepath.Join(dir, stagingMarker), []byte(marker), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return
+}
+
+// commit verifies the go tree root of version and renames it into GOHOME,
+// replacing a broken install of version there. An adopted installation of
+// version is never replaced, the GOHOME one takes precedence over it.
+func (m *Manager) commit(ctx context.Context, staging, root, version string) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	if err = Verify(root, version); err != nil {
+		return
+	}
+
+	var target = filepath.Join(m.home, version)
+	var broken = filepath.Join(staging, "broken")
+	if _, err = os.Lstat(target); err == nil {
+		if err = rename(target, broken); err != nil {
+			return
+		}
+	}
+
+	if err = rename(root, target); err != nil {
+		if files.Exists(broken) {
+			rename(broken, target)
+		}
+		return
+	}
+
+	return nil
+}
+
+// mirrors calls fn with the url of the release file name on each mirror,
+// until it succeeds or ctx is done.
+func (m *Manager) mirrors(ctx context.Context, name string, bar *pb.ProgressBar, fn func(url string) error) (err error) {
+	for _, url := range m.index.FileURLs(name) {
+		if err = fn(url); err == nil || ctx.Err() != nil {
+			return
+		}
+		if bar == nil {
+			fmt.Fprintln(m.out, name, "download failed:", err)
+		}
+	}
+	return
+}
+
+// Install downloads, verifies and unpacks the release version into GOHOME
+// and returns "installed" or "already installed". The phases and the
+// progress are written to the output.
+func (m *Manager) Install(ctx context.Context, version string, opts InstallOptions) (status string, err error) {
+	return m.install(ctx, version, opts, nil)
+}
+
+// Installed is the result of installing a version with InstallVersions.
+type Installed struct {
+	Version string
+	Status  string
+	Err     error
+}
+
+// InstallVersions installs the release versions with up to jobs concurrent
+// installs. Several versions are shown as a pool of progress bars on the
+// terminal, one per version.
+func (m *Manager) InstallVersions(ctx context.Context, versions []string, jobs int, opts InstallOptions) []Installed {
+	var results = make([]Installed, len(versions))
+	var bars = make([]*pb.ProgressBar, len(versions))
+
+	var pool *pb.Pool
+	if len(versions) > 1 {
+		for i, version := range versions {
+			bars[i] = pb.Default.New(0).Set(pb.Bytes, true).Set("prefix", version+" waiting")
+		}
+
+		var err error
+		if pool, err = pb.StartPool(bars...); err != nil {
+			// not a terminal, the bars are not rendered
+			debug.Println("gvm: progress pool error:", err.Error())
+			for _, bar := range bars {
+				bar.Set(pb.Static, true)
+			}
+			pool = nil
+		}
+	}
+
+	if jobs > len(versions) {
+		jobs = len(versions)
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var work = make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < jobs; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i].Version = versions[i]
+				results[i].Status, results[i].Err = m.install(ctx, versions[i], opts, bars[i])
+				if bars[i] == nil {
+					continue
+				}
+				if results[i].Err != nil {
+					bars[i].Set("prefix", versions[i]+" failed")
+				} else {
+					bars[i].Set("prefix", versions[i]+" "+results[i].Status)
+				}
+			}
+		}()
+	}
+
+	for i := range versions {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	if pool != nil {
+		pool.Stop()
+	}
+
+	return results
+}
+
+// lock locks the installs of version and returns the unlock func.
+func (m *Manager) lock(version string) func() {
+	m.mu.Lock()
+	if m.installing == nil {
+		m.installing = make(map[string]*sync.Mutex)
+	}
+	var l, exists = m.installing[version]
+	if !exists {
+		l = new(sync.Mutex)
+		m.installing[version] = l
+	}
+	m.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// install is Install showing the progress on bar, or on the output if bar
+// is nil.
+func (m *Manager) install(ctx context.Context, version string, opts InstallOptions, bar *pb.ProgressBar) (status string, err error) {
+	defer m.lock(version)()
+
+	if m.Exists(version) {
+		return "already installed", nil
+	}
+
+	var phase = func(name string) {
+		if bar == nil {
+			fmt.Fprintln(m.out, version, name+": ")
+		} else {
+			bar.Set("prefix", version+" "+name)
+		}
+	}
+
+	archive, err := m.index.Find(version)
+	if err != nil {
+		return
+	}
+	if archive.Sha256 == "" {
+		return "", fmt.Errorf("%s: no sha256 checksum published, refusing to install", version)
+	}
+
+	// downloads go to the archive cache, resumed or reused from there
+	var filename = m.cache.Path(archive.Sha256, golang.Filename(version))
+	_, cached := m.cache.Lookup(archive.Sha256, golang.Filename(version))
+	if cached {
+		debug.Println(version, "archive cached:", filename)
+	}
+
+	var stream = opts.Stream && golang.CanStream() && !cached
+	if opts.Stream && !golang.CanStream() {
+		debug.Println(version, "archive can not be streamed, downloading it")
+	}
+
+	if !stream && !cached {
+		phase("installing")
+		if err = m.mirrors(ctx, golang.Filename(version), bar, func(url string) error {
+			return m.downloader.Download(ctx, url, filename, archive.Sha256, bar)
+		}); err != nil {
+			return
+		}
+	}
+
+	staging, err := m.stage(version)
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	var root = filepath.Join(staging, "go")
+	if stream {
+		var keep string
+		if opts.KeepArchive {
+			keep = filename
+		}
+
+		phase("streaming")
+		err = m.mirrors(ctx, golang.Filename(version), bar, func(url string) error {
+			return m.downloader.Stream(ctx, url, archive.Sha256, keep, bar, func(r io.Reader) error {
+				// a retry unpacks from the start
+				if err := os.RemoveAll(root); err != nil {
+					return err
+				}
+				return golang.DecodeReader(r, staging)
+			})
+		})
+	} else {
+		phase("unpacking")
+		var decode, done = m.bar(bar)
+		err = golang.Decode(filename, staging, decode)
+		done()
+	}
+	if err != nil {
+		return
+	}
+
+	if err = m.commit(ctx, staging, root, version); err != nil {
+		return
+	}
+
+	return "installed", nil
+}
+
+// findRoot returns the go tree in dir: dir itself or its only directory,
+// holding a VERSION file.
+func findRoot(dir string) (root string, err error) {
+	if files.IsFile(filepath.Join(dir, "VERSION")) {
+		return dir, nil
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if root != "" {
+			return "", fmt.Errorf("%s: more than one directory, no go tree", dir)
+		}
+		root = filepath.Join(dir, entry.Name())
+	}
+
+	if root == "" || !files.IsFile(filepath.Join(root, "VERSION")) {
+		return "", fmt.Errorf("%s: no VERSION file, no go tree", dir)
+	}
+
+	return
+}
+
+// InstallFrom installs the go archive or directory source, verifying the
+// sha256 of an archive if checksum is not empty. The version is read from
+// the VERSION file of the go tree.
+func (m *Manager) InstallFrom(ctx context.Context, source, checksum string) (version, status string, err error) {
+	stat, err := os.Stat(source)
+	if err != nil {
+		return
+	}
+
+	if checksum != "" {
+		if stat.IsDir() {
+			return "", "", fmt.Errorf("%s: a checksum verifies an archive, not a directory", source)
+		}
+		if err = utils.VerifyFile(source, checksum); err != nil {
+			return
+		}
+	}
+
+	staging, err := m.stage("local")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	var root = filepath.Join(staging, "go")
+	if stat.IsDir() {
+		var src string
+		if src, err = findRoot(source); err != nil {
+			return
+		}
+		fmt.Fprintln(m.out, source, "copying: ")
+		err = files.CopyDir(src, root)
+	} else {
+		fmt.Fprintln(m.out, source, "unpacking: ")
+		var bar, done = m.bar(nil)
+		err = golang.Decode(source, root, bar)
+		if done(); err != nil {
+			return
+		}
+		root, err = findRoot(root)
+	}
+	if err != nil {
+		return
+	}
+
+	if version, err = golang.RootVersion(root); err != nil {
+		return
+	}
+
+	if m.Exists(version) {
+		return version, "already installed", nil
+	}
+
+	if err = m.commit(ctx, staging, root, version); err != nil {
+		return
+	}
+
+	return version, "installed", nil
+}
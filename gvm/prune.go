@@ -0,0 +1,132 @@
+package gvm
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/golang"
+	"github.com/zooyer/gvm/interval/project"
+)
+
+// PruneOptions are the retention rules of Prune. The version active in
+// Dir, the global version and the versions pinned by remembered projects
+// are always kept.
+type PruneOptions struct {
+	// Dir is the directory whose active version is kept, default the
+	// working directory.
+	Dir string
+
+	// KeepUsed keeps the versions used within this window.
+	KeepUsed time.Duration
+
+	// KeepPatches is the number of patch releases of every minor version
+	// kept.
+	KeepPatches int
+
+	// DryRun reports what would be removed without removing it.
+	DryRun bool
+}
+
+// Pruned is a released version in GOHOME considered by Prune. Status is
+// "kept" with the Reason, "removed" or "would be removed", Size is the
+// size of the version removed.
+type Pruned struct {
+	Version string
+	Status  string
+	Reason  string
+	Size    int64
+	Err     error
+}
+
+// dirSize returns the size of the files in dir.
+func dirSize(dir string) (size int64) {
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}
+
+// Prune uninstalls the released versions in GOHOME which no retention
+// rule of opts keeps, from oldest to newest, and forgets the remembered
+// project files which are gone or pin no version. Adopted versions and
+// builds are never pruned.
+func (m *Manager) Prune(opts PruneOptions) (pruned []Pruned) {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+
+	var versions []string
+	for _, version := range m.Installed() {
+		if _, err := golang.ParseVersion(version); err != nil || m.Root(version) != filepath.Join(m.home, version) {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	golang.Sort(versions)
+
+	var keep = make(map[string]string)
+	var mark = func(version, reason string) {
+		if _, exists := keep[version]; !exists {
+			keep[version] = reason
+		}
+	}
+
+	if version, source := m.Active(opts.Dir); version != "" {
+		mark(version, "active, set by "+source)
+	}
+	if version := m.Global(); version != "" {
+		mark(version, "set")
+	}
+
+	var forgotten []string
+	for _, filename := range m.Projects() {
+		pin, err := project.Read(filename)
+		if err != nil {
+			debug.Println("gvm: forget project:", err.Error())
+			forgotten = append(forgotten, filename)
+			continue
+		}
+		if version, err := golang.Resolve(pin.Version, versions); err == nil {
+			mark(version, "pinned by "+filename)
+		}
+	}
+
+	for version, t := range m.LastUsed() {
+		if time.Since(t) < opts.KeepUsed {
+			mark(version, "used "+t.Format("2006-01-02"))
+		}
+	}
+
+	for _, version := range golang.NewestPatches(versions, opts.KeepPatches) {
+		mark(version, "newest patch")
+	}
+
+	for _, version := range versions {
+		if reason, exists := keep[version]; exists {
+			pruned = append(pruned, Pruned{Version: version, Status: "kept", Reason: reason})
+			continue
+		}
+
+		var p = Pruned{Version: version, Status: "would be removed", Size: dirSize(filepath.Join(m.home, version))}
+		if !opts.DryRun {
+			p.Status = "removed"
+			if _, p.Err = m.Uninstall(version); p.Err != nil {
+				p.Size = 0
+			}
+		}
+		pruned = append(pruned, p)
+	}
+
+	if !opts.DryRun && len(forgotten) > 0 {
+		if err := m.Forget(forgotten...); err != nil {
+			debug.Println("gvm: forget projects error:", err.Error())
+		}
+	}
+
+	return
+}
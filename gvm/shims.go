@@ -0,0 +1,92 @@
+package gvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/files"
+	"github.com/zooyer/gvm/interval/shell"
+)
+
+// ShimsDir returns the dir of the tool launchers written by Rehash.
+func (m *Manager) ShimsDir() string {
+	return filepath.Join(m.home, "shims")
+}
+
+// shimScript returns the launcher of tool, running it by the shim command
+// of the gvm binary.
+func (m *Manager) shimScript(tool string) (name, script string) {
+	if runtime.GOOS == "windows" {
+		return tool + ".cmd", fmt.Sprintf("@echo off\r\n\"%s\" shim %s %%*\r\n", m.executable, tool)
+	}
+	return tool, fmt.Sprintf("#!/bin/sh\nexec %s shim %s \"$@\"\n", shell.Quote(m.executable), shell.Quote(tool))
+}
+
+// Rehash writes the launchers of the tools of all installed versions and
+// removes the launchers of tools no longer installed.
+func (m *Manager) Rehash() (tools []string, err error) {
+	var dir = m.ShimsDir()
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	var shims = make(map[string]bool)
+	for _, version := range m.Installed() {
+		entries, err := ioutil.ReadDir(filepath.Join(m.Root(version), "bin"))
+		if err != nil {
+			debug.Println("gvm: read bin error:", err.Error())
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			var tool = strings.TrimSuffix(entry.Name(), ".exe")
+			name, script := m.shimScript(tool)
+			if shims[name] {
+				continue
+			}
+			shims[name] = true
+			tools = append(tools, tool)
+
+			if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+				return tools, err
+			}
+		}
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !shims[entry.Name()] {
+			if err = os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return
+			}
+		}
+	}
+
+	sort.Strings(tools)
+
+	return
+}
+
+// RefreshShims rehashes the launchers after the installed versions
+// changed, if Rehash wrote them before.
+func (m *Manager) RefreshShims() (err error) {
+	if !files.Exists(m.ShimsDir()) {
+		return
+	}
+
+	_, err = m.Rehash()
+	return
+}
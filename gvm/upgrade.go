@@ -0,0 +1,103 @@
+package gvm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/golang"
+	"github.com/zooyer/gvm/interval/project"
+)
+
+// Upgrade is an installed version with a newer patch release.
+type Upgrade struct {
+	Version string `json:"version"`
+	Latest  string `json:"latest"`
+	Active  bool   `json:"active"`
+}
+
+// newestPatch returns the newest stable patch release of the minor version
+// of version in available, or version if there is none newer.
+func newestPatch(version string, available []string) string {
+	v, err := golang.ParseVersion(version)
+	if err != nil {
+		return version
+	}
+
+	newest, err := golang.Resolve(fmt.Sprintf("%d.%d", v.Major(), v.Minor()), available)
+	if err != nil {
+		return version
+	}
+	if n, err := golang.ParseVersion(newest); err != nil || !v.Less(n) {
+		return version
+	}
+
+	return newest
+}
+
+// NewestPatch returns the newest stable patch release of the minor version
+// of version in the index, or version if there is none newer.
+func (m *Manager) NewestPatch(version string) string {
+	return newestPatch(version, m.index.Available())
+}
+
+// Outdated returns the installed versions with a newer patch release, from
+// oldest to newest. Active marks the version active in dir.
+func (m *Manager) Outdated(dir string) (upgrades []Upgrade, err error) {
+	var available = m.index.Available()
+	if len(available) == 0 {
+		return nil, errors.New("no version index, run without --offline")
+	}
+
+	var versions = m.Installed()
+	golang.Sort(versions)
+	current, _ := m.Active(dir)
+
+	upgrades = make([]Upgrade, 0)
+	for _, version := range versions {
+		if latest := newestPatch(version, available); latest != version {
+			upgrades = append(upgrades, Upgrade{Version: version, Latest: latest, Active: version == current})
+		}
+	}
+
+	return
+}
+
+// Pin is a project file whose pin was upgraded, or failed to be.
+type Pin struct {
+	File string
+	Err  error
+}
+
+// UpgradePins changes the pins of old to version in the remembered project
+// files and the nearest project file of dir. The upgraded files are
+// remembered, files which are gone are skipped.
+func (m *Manager) UpgradePins(dir, old, version string) (pins []Pin) {
+	var filenames = m.Projects()
+	if pin, ok := project.Find(dir); ok {
+		filenames = append(filenames, pin.File)
+	}
+
+	var seen = make(map[string]bool)
+	for _, filename := range filenames {
+		if seen[filename] {
+			continue
+		}
+		seen[filename] = true
+
+		ok, err := project.Upgrade(filename, old, version)
+		switch {
+		case err != nil && os.IsNotExist(err):
+		case err != nil:
+			pins = append(pins, Pin{File: filename, Err: err})
+		case ok:
+			if err = m.Remember(filename); err != nil {
+				debug.Println("gvm: remember project error:", err.Error())
+			}
+			pins = append(pins, Pin{File: filename})
+		}
+	}
+
+	return
+}
@@ -0,0 +1,76 @@
+package gvm
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/files"
+)
+
+func (m *Manager) usageFile() string {
+	return filepath.Join(m.home, "usage.json")
+}
+
+// LastUsed returns when the versions were last marked used.
+func (m *Manager) LastUsed() map[string]time.Time {
+	var usage = make(map[string]time.Time)
+	if err := files.ReadJSON(m.usageFile(), &usage); err != nil && !os.IsNotExist(err) {
+		debug.Println("gvm: read usage error:", err.Error())
+	}
+	return usage
+}
+
+// MarkUsed records that version is used now.
+func (m *Manager) MarkUsed(version string) error {
+	var usage = m.LastUsed()
+	usage[version] = time.Now()
+	return files.WriteJSON(m.usageFile(), usage, 0644)
+}
+
+func (m *Manager) projectsFile() string {
+	return filepath.Join(m.home, "projects.json")
+}
+
+// Projects returns the project files remembered pinning a version.
+func (m *Manager) Projects() (projects []string) {
+	if err := files.ReadJSON(m.projectsFile(), &projects); err != nil && !os.IsNotExist(err) {
+		debug.Println("gvm: read projects error:", err.Error())
+	}
+	return
+}
+
+// Remember records the project file filename pinning a version.
+func (m *Manager) Remember(filename string) error {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	var projects = m.Projects()
+	for _, known := range projects {
+		if known == filename {
+			return nil
+		}
+	}
+
+	return files.WriteJSON(m.projectsFile(), append(projects, filename), 0644)
+}
+
+// Forget removes the project files filenames from the remembered ones.
+func (m *Manager) Forget(filenames ...string) error {
+	var forget = make(map[string]bool)
+	for _, filename := range filenames {
+		forget[filename] = true
+	}
+
+	var projects []string
+	for _, known := range m.Projects() {
+		if !forget[known] {
+			projects = append(projects, known)
+		}
+	}
+
+	return files.WriteJSON(m.projectsFile(), projects, 0644)
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zooyer/gvm/interval/debug"
+)
+
+// Cache is an archive cache directory. An archive is stored in a directory
+// named by its sha256, so it is shared by every install of the same file,
+// whatever mirror it came from.
+type Cache struct {
+	Dir string
+}
+
+// partialSuffix marks the state of an interrupted download.
+const partialSuffix = ".partial"
+
+// Entry is a cached archive.
+type Entry struct {
+	Sha256  string    `json:"sha256"`
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Used    time.Time `json:"used"`
+	Partial bool      `json:"partial"`
+
+	dir string
+}
+
+// Path returns the file name of the entry.
+func (e Entry) Path() string {
+	return Cache{Dir: e.dir}.Path(e.Sha256, e.Name)
+}
+
+// Path returns the file name of the archive name with the given sha256.
+func (c Cache) Path(sha256, name string) string {
+	return filepath.Join(c.Dir, strings.ToLower(sha256), name)
+}
+
+func hashFile(filename string) (sum string, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	var h = sha256.New()
+	if _, err = io.Copy(h, file); err != nil {
+		return
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Lookup returns the cached archive name with the given sha256, if it is
+// complete and its content matches. A lookup marks the archive as used.
+func (c Cache) Lookup(sha256, name string) (filename string, ok bool) {
+	filename = c.Path(sha256, name)
+	if _, err := os.Stat(filename + partialSuffix); err == nil {
+		return "", false
+	}
+
+	sum, err := hashFile(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			debug.Println("cache: lookup error:", err.Error())
+		}
+		return "", false
+	}
+	if !strings.EqualFold(sum, sha256) {
+		debug.Println("cache: remove corrupt archive:", filename)
+		os.RemoveAll(filepath.Dir(filename))
+		return "", false
+	}
+
+	Touch(filename)
+
+	return filename, true
+}
+
+// Touch marks the archive filename as used now.
+func Touch(filename string) {
+	var now = time.Now()
+	if err := os.Chtimes(filename, now, now); err != nil {
+		debug.Println("cache: touch error:", err.Error())
+	}
+}
+
+// List returns the cached archives, the most recently used first.
+func (c Cache) List() (entries []Entry, err error) {
+	dirs, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		infos, err := ioutil.ReadDir(filepath.Join(c.Dir, dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var partials = make(map[string]bool)
+		for _, info := range infos {
+			if strings.HasSuffix(info.Name(), partialSuffix) {
+				partials[strings.TrimSuffix(info.Name(), partialSuffix)] = true
+			}
+		}
+
+		for _, info := range infos {
+			if info.IsDir() || strings.HasSuffix(info.Name(), partialSuffix) {
+				continue
+			}
+			entries = append(entries, Entry{
+				Sha256:  dir.Name(),
+				Name:    info.Name(),
+				Size:    info.Size(),
+				Used:    info.ModTime(),
+				Partial: partials[info.Name()],
+				dir:     c.Dir,
+			})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Used.After(entries[j].Used)
+	})
+
+	return
+}
+
+// Total returns the size of entries.
+func Total(entries []Entry) (size int64) {
+	for _, entry := range entries {
+		size += entry.Size
+	}
+	return
+}
+
+// Remove removes the archive of entry, as listed by List, and its partial
+// download state.
+func Remove(entry Entry) error {
+	if err := os.Remove(entry.Path()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	os.Remove(entry.Path() + partialSuffix)
+
+	// the directory is left if it holds other files
+	os.Remove(filepath.Dir(entry.Path()))
+
+	return nil
+}
+
+// Prune removes the least recently used archives until the cache is not
+// larger than max, and returns the removed archives.
+func (c Cache) Prune(max int64) (removed []Entry, err error) {
+	entries, err := c.List()
+	if err != nil {
+		return
+	}
+
+	var size = Total(entries)
+	for i := len(entries) - 1; i >= 0 && size > max; i-- {
+		if err = Remove(entries[i]); err != nil {
+			return
+		}
+		size -= entries[i].Size
+		removed = append(removed, entries[i])
+	}
+
+	return
+}
+
+// Clean removes all cached archives and returns them.
+func (c Cache) Clean() (removed []Entry, err error) {
+	return c.Prune(0)
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func put(t *testing.T, c Cache, name, data string, used time.Time) Entry {
+	var sum = sha256.Sum256([]byte(data))
+	var entry = Entry{Sha256: hex.EncodeToString(sum[:]), Name: name, dir: c.Dir}
+	if err := os.MkdirAll(filepath.Dir(entry.Path()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(entry.Path(), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(entry.Path(), used, used); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestLookup(t *testing.T) {
+	var c = Cache{Dir: t.TempDir()}
+
+	var entry = put(t, c, "go1.21.0.linux-amd64.tar.gz", "go1.21.0", time.Now().Add(-time.Hour))
+	if filename, ok := c.Lookup(entry.Sha256, entry.Name); !ok || filename != entry.Path() {
+		t.Fatalf("lookup: got %q %v", filename, ok)
+	}
+
+	// a partial download is not complete
+	if err := ioutil.WriteFile(entry.Path()+partialSuffix, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Lookup(entry.Sha256, entry.Name); ok {
+		t.Fatal("partial archive found")
+	}
+	os.Remove(entry.Path() + partialSuffix)
+
+	// a corrupt archive is removed
+	if err := ioutil.WriteFile(entry.Path(), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Lookup(entry.Sha256, entry.Name); ok {
+		t.Fatal("corrupt archive found")
+	}
+	if _, err := os.Stat(entry.Path()); !os.IsNotExist(err) {
+		t.Fatal("corrupt archive not removed")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	var c = Cache{Dir: t.TempDir()}
+
+	var now = time.Now()
+	put(t, c, "go1.20.linux-amd64.tar.gz", "go1.20", now.Add(-3*time.Hour))
+	put(t, c, "go1.21.0.linux-amd64.tar.gz", "go1.21.0", now.Add(-2*time.Hour))
+	put(t, c, "go1.22.0.linux-amd64.tar.gz", "go1.22.0", now.Add(-time.Hour))
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Name != "go1.22.0.linux-amd64.tar.gz" {
+		t.Fatalf("list: got %v", entries)
+	}
+
+	removed, err := c.Prune(Total(entries) - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].Name != "go1.20.linux-amd64.tar.gz" {
+		t.Fatalf("prune: removed %v", removed)
+	}
+	if _, err = os.Stat(filepath.Dir(removed[0].Path())); !os.IsNotExist(err) {
+		t.Fatal("pruned archive dir not removed")
+	}
+
+	if removed, err = c.Clean(); err != nil || len(removed) != 2 {
+		t.Fatalf("clean: removed %v, %v", removed, err)
+	}
+	if entries, _ = c.List(); len(entries) != 0 {
+		t.Fatalf("clean: left %v", entries)
+	}
+}
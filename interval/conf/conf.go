@@ -2,7 +2,9 @@ package conf
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,13 +15,73 @@ type unmarshaler interface {
 
 type Duration time.Duration
 
+// Size is a size in bytes, like 1024, 500M or 2GB.
+type Size int64
+
 var Debug = false
 
 var Timeout = 20 * Duration(time.Second)
 
+// Retry is the number of times a failed download is retried.
+var Retry = 3
+
+// Mirror is a comma separated list of download mirrors, tried in order.
+var Mirror = ""
+
+// Offline uses only the cached version index and the installed versions.
+var Offline = false
+
+// CacheTTL is how long the cached version index is used before refetching.
+var CacheTTL = 24 * Duration(time.Hour)
+
+// Jobs is the number of versions installed concurrently.
+var Jobs = 3
+
+// Stream unpacks archives while they are downloaded, without storing them.
+var Stream = false
+
+// KeepArchive keeps a streamed archive in the archive cache.
+var KeepArchive = false
+
+// CacheDir is the archive cache directory, empty is GOHOME/cache.
+var CacheDir = ""
+
+// CacheSize limits the archive cache, the least recently used archives are
+// pruned after an install. Zero is unlimited.
+var CacheSize Size
+
+// GitRemote is the go repository built by "gvm install tip".
+var GitRemote = "https://go.googlesource.com/go"
+
+// GoToolchain is the GOTOOLCHAIN of commands run by gvm exec and the shims,
+// local keeps go1.21+ from switching to another toolchain. Empty keeps the
+// environment.
+var GoToolchain = ""
+
+// KeepPatches is the number of patch releases of every minor version kept
+// by prune.
+var KeepPatches = 2
+
+// KeepUsed keeps versions used within this window from prune.
+var KeepUsed = 30 * Duration(24*time.Hour)
+
 var addr = map[string]interface{}{
-	"debug":   &Debug,
-	"timeout": &Timeout,
+	"debug":      &Debug,
+	"timeout":    &Timeout,
+	"retry":      &Retry,
+	"mirror":     &Mirror,
+	"offline":    &Offline,
+	"cache_ttl":  &CacheTTL,
+	"jobs":       &Jobs,
+	"stream":     &Stream,
+	"cache_dir":  &CacheDir,
+	"git_remote": &GitRemote,
+
+	"cache_size":   &CacheSize,
+	"keep_archive": &KeepArchive,
+	"gotoolchain":  &GoToolchain,
+	"keep_patches": &KeepPatches,
+	"keep_used":    &KeepUsed,
 }
 
 func (d Duration) Duration() time.Duration {
@@ -51,6 +113,8 @@ func (d *Duration) UnmarshalEnv(data []byte) (err error) {
 		*dd = i * time.Minute
 	case "h":
 		*dd = i * time.Hour
+	case "d":
+		*dd = i * 24 * time.Hour
 	}
 
 	return
@@ -60,24 +124,100 @@ func (d Duration) String() string {
 	return time.Duration(d).String()
 }
 
+var units = map[string]int64{
+	"":  1,
+	"B": 1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// ParseSize parses a size like 1024, 500M, 2G or 1.5GB.
+func ParseSize(s string) (size Size, err error) {
+	var value = strings.ToUpper(strings.TrimSpace(s))
+	var index = strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if index < 0 {
+		index = len(value)
+	}
+
+	var unit = strings.TrimSpace(value[index:])
+	if len(unit) == 2 && unit[1] == 'B' {
+		unit = unit[:1]
+	}
+
+	n, err := strconv.ParseFloat(value[:index], 64)
+	mul, exists := units[unit]
+	if err != nil || !exists || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+
+	return Size(n * float64(mul)), nil
+}
+
+func (s *Size) UnmarshalEnv(data []byte) (err error) {
+	*s, err = ParseSize(string(data))
+	return
+}
+
+func (s Size) String() string {
+	var n = float64(s)
+	for _, unit := range []string{"B", "K", "M", "G"} {
+		if n < 1024 {
+			if unit == "B" {
+				return fmt.Sprintf("%d%s", int64(n), unit)
+			}
+			return fmt.Sprintf("%.1f%s", n, unit)
+		}
+		n /= 1024
+	}
+	return fmt.Sprintf("%.1fT", n)
+}
+
+func bind(val string, v interface{}) (err error) {
+	switch value := v.(type) {
+	case unmarshaler:
+		return value.UnmarshalEnv([]byte(val))
+	case *bool, *int8, *int16, *int32, *int64, *uint8, *uint16, *uint32, *uint64:
+		return json.Unmarshal([]byte(val), value)
+	case *string:
+		*value = val
+	default:
+		return json.Unmarshal([]byte(val), value)
+	}
+
+	return
+}
+
 func BindEnv(key string, v interface{}) (err error) {
 	if val := os.Getenv(key); val != "" {
-		switch value := v.(type) {
-		case unmarshaler:
-			return value.UnmarshalEnv([]byte(val))
-		case *bool, *int8, *int16, *int32, *int64, *uint8, *uint16, *uint32, *uint64:
-			return json.Unmarshal([]byte(val), value)
-		case *string:
-			*value = val
-		default:
-			return json.Unmarshal([]byte(val), value)
-		}
+		return bind(val, v)
 	}
 
 	return
 }
 
+// Has reports whether key is a known config key.
+func Has(key string) bool {
+	_, exists := addr[key]
+	return exists
+}
+
+// Set overrides the config key with val, e.g. from a command line flag.
+func Set(key, val string) error {
+	v, exists := addr[key]
+	if !exists {
+		return fmt.Errorf("conf: unknown key %s", key)
+	}
+	return bind(val, v)
+}
+
 func init() {
+	// GVM_DL_URL is an alias of GVM_MIRROR
+	BindEnv("GVM_DL_URL", &Mirror)
+
 	for key, addr := range addr {
 		BindEnv("GVM_"+strings.ToUpper(key), addr)
 	}
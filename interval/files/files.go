@@ -1,8 +1,13 @@
 package files
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/zooyer/gvm/interval/debug"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 func Exists(filename string) bool {
@@ -31,6 +36,15 @@ func IsFile(filename string) bool {
 	return !stat.IsDir()
 }
 
+// RealPath returns the absolute path of filename with links resolved.
+func RealPath(filename string) (string, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(filename)
+}
+
 func AppendFile(filename string, data []byte, perm os.FileMode) error {
 	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
 	if err != nil {
@@ -43,3 +57,123 @@ func AppendFile(filename string, data []byte, perm os.FileMode) error {
 	}
 	return err
 }
+
+func ReadJSON(filename string, v interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteJSON writes v to a temporary file and renames it to filename, so
+// readers never observe a partially written file.
+func WriteJSON(filename string, v interface{}, perm os.FileMode) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		debug.Println("files: write json error:", err.Error())
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		debug.Println("files: write json error:", err.Error())
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	return err
+}
+
+func copyFile(src, dst string, info os.FileInfo) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm()|0200)
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(out, in)
+	if err1 := out.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Chmod(dst, info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	return
+}
+
+// CopyDir copies the tree src to dst, which must not exist. Modes and times
+// are kept, symlinks are copied as links.
+func CopyDir(src, dst string) error {
+	var dirs = make(map[string]os.FileInfo)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		var target = filepath.Join(dst, rel)
+
+		switch mode := info.Mode(); {
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case mode.IsDir():
+			if rel == "." {
+				if _, err = os.Lstat(dst); err == nil {
+					return fmt.Errorf("copy %s: %s exists", src, dst)
+				}
+			}
+			dirs[target] = info
+			return os.MkdirAll(target, 0755)
+		case mode.IsRegular():
+			return copyFile(path, target, info)
+		}
+
+		return fmt.Errorf("copy %s: unsupported file mode %s", path, info.Mode())
+	})
+	if err != nil {
+		debug.Println("files: copy dir error:", err.Error())
+		return err
+	}
+
+	// directories are written to during the walk
+	for dir, info := range dirs {
+		if err = os.Chmod(dir, info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err = os.Chtimes(dir, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyDir(t *testing.T) {
+	var src = filepath.Join(t.TempDir(), "go")
+	var dst = filepath.Join(t.TempDir(), "go")
+
+	if err := os.MkdirAll(filepath.Join(src, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "VERSION"), []byte("go1.21.5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "bin", "go"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("bin/go", filepath.Join(src, "go")); err != nil {
+		t.Skip("symlink:", err)
+	}
+
+	if err := CopyDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(filepath.Join(dst, "bin", "go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0755 {
+		t.Errorf("bin/go mode: got %s", stat.Mode())
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "go")); err != nil || link != "bin/go" {
+		t.Errorf("go link: got %q, %v", link, err)
+	}
+
+	if err = CopyDir(src, dst); err == nil {
+		t.Error("copy to an existing dir succeeded")
+	}
+}
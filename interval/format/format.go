@@ -0,0 +1,55 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// JSON is the format writing indented json.
+const JSON = "json"
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": strings.Join,
+}
+
+// Write writes v to w in format: "json", or a text/template executed for
+// every element if v is a slice, each followed by a newline.
+func Write(w io.Writer, format string, v interface{}) (err error) {
+	if format == JSON {
+		var encoder = json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	tmpl, err := template.New("format").Funcs(funcs).Parse(format)
+	if err != nil {
+		return fmt.Errorf("invalid format: %w", err)
+	}
+
+	var items = []interface{}{v}
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Slice {
+		items = items[:0]
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, value.Index(i).Interface())
+		}
+	}
+
+	for _, item := range items {
+		if err = tmpl.Execute(w, item); err != nil {
+			return
+		}
+		if _, err = io.WriteString(w, "\n"); err != nil {
+			return
+		}
+	}
+
+	return
+}
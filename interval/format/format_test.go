@@ -0,0 +1,45 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+type item struct {
+	Version   string `json:"version"`
+	Installed bool   `json:"installed"`
+}
+
+func TestWrite(t *testing.T) {
+	var items = []item{{"go1.21.5", true}, {"go1.22.0", false}}
+
+	var tests = []struct {
+		format string
+		v      interface{}
+		want   string
+	}{
+		{JSON, items, "[\n  {\n    \"version\": \"go1.21.5\",\n    \"installed\": true\n  },\n  {\n    \"version\": \"go1.22.0\",\n    \"installed\": false\n  }\n]\n"},
+		{"{{.Version}}", items, "go1.21.5\ngo1.22.0\n"},
+		{"{{.Version}} {{.Installed}}", items[0], "go1.21.5 true\n"},
+		{"{{json .}}", items[:1], "{\"version\":\"go1.21.5\",\"installed\":true}\n"},
+		{"{{.Version}}", []item{}, ""},
+	}
+
+	for _, test := range tests {
+		var buf strings.Builder
+		if err := Write(&buf, test.format, test.v); err != nil {
+			t.Errorf("%q: %v", test.format, err)
+			continue
+		}
+		if buf.String() != test.want {
+			t.Errorf("%q: got %q, want %q", test.format, buf.String(), test.want)
+		}
+	}
+
+	if err := Write(&strings.Builder{}, "{{.Version", items); err == nil {
+		t.Error("invalid template accepted")
+	}
+	if err := Write(&strings.Builder{}, "{{.Missing}}", items); err == nil {
+		t.Error("missing field accepted")
+	}
+}
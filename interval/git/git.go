@@ -0,0 +1,120 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+
+	"github.com/zooyer/gvm/interval/files"
+	"github.com/zooyer/gvm/interval/utils"
+)
+
+// Spec is a git source of go, a remote url and a ref.
+type Spec struct {
+	URL string `json:"url"`
+	Ref string `json:"ref"`
+}
+
+func (s Spec) String() string {
+	return "git:" + s.URL + "@" + s.Ref
+}
+
+// pathStart returns the index of the repository path in url, after the
+// scheme, user and host of https://user@host/path or user@host:path.
+func pathStart(url string) int {
+	if index := strings.Index(url, "://"); index >= 0 {
+		if slash := strings.Index(url[index+3:], "/"); slash >= 0 {
+			return index + 3 + slash
+		}
+		return len(url)
+	}
+	if index := strings.Index(url, ":"); index >= 0 && !strings.Contains(url[:index], "/") {
+		return index + 1
+	}
+	return 0
+}
+
+// ParseSpec parses git:<url>@<ref>, the ref defaults to HEAD. The ref
+// follows the first @ of the repository path, it may contain slashes like
+// refs/tags/v1. A url or ref starting with "-" is refused, git would read
+// it as an option.
+func ParseSpec(s string) (spec Spec, ok bool) {
+	if !strings.HasPrefix(s, "git:") {
+		return
+	}
+	spec.URL, spec.Ref = strings.TrimPrefix(s, "git:"), "HEAD"
+
+	// the @ of git@host:path is not a ref
+	var start = pathStart(spec.URL)
+	if index := strings.Index(spec.URL[start:], "@"); index >= 0 {
+		spec.URL, spec.Ref = spec.URL[:start+index], spec.URL[start+index+1:]
+	}
+
+	return spec, spec.URL != "" && spec.Ref != "" && !strings.HasPrefix(spec.URL, "-") && !strings.HasPrefix(spec.Ref, "-")
+}
+
+func run(dir string, w io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+	if w == nil {
+		w = ioutil.Discard
+	}
+	if err := utils.RunIn(dir, nil, io.MultiWriter(w, &stderr), "git", args...); err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Fetch fetches the ref of url into the bare repository dir, cloning it
+// first, and returns the commit hash. Git output is written to w. The url
+// and ref follow "--", they are never read as options.
+func Fetch(dir string, spec Spec, w io.Writer) (commit string, err error) {
+	if !files.IsDir(dir) {
+		if err = run("", w, "clone", "--bare", "--quiet", "--", spec.URL, dir); err != nil {
+			return
+		}
+	} else if err = run(dir, w, "remote", "set-url", "--", "origin", spec.URL); err != nil {
+		return
+	}
+
+	if err = run(dir, w, "fetch", "--quiet", "--", "origin", spec.Ref); err != nil {
+		return
+	}
+
+	var out bytes.Buffer
+	if err = run(dir, &out, "rev-parse", "FETCH_HEAD^{commit}"); err != nil {
+		return
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+// Archive extracts the tree of commit of the repository dir into dest.
+func Archive(dir, commit, dest string) (err error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "archive", "--format=tar", commit)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err = cmd.Start(); err != nil {
+		return
+	}
+
+	if err = utils.Untar(out, dest); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return
+	}
+
+	if err = cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSpec(t *testing.T) {
+	var tests = map[string]Spec{
+		"git:https://go.googlesource.com/go@master": {URL: "https://go.googlesource.com/go", Ref: "master"},
+		"git:/srv/go.git":                  {URL: "/srv/go.git", Ref: "HEAD"},
+		"git:git@github.com:me/go":         {URL: "git@github.com:me/go", Ref: "HEAD"},
+		"git:git@github.com:me/go@dev.fix": {URL: "git@github.com:me/go", Ref: "dev.fix"},
+
+		// refs with slashes
+		"git:https://go.googlesource.com/go@refs/tags/go1.21.5":     {URL: "https://go.googlesource.com/go", Ref: "refs/tags/go1.21.5"},
+		"git:https://github.com/me/go@feature/x":                    {URL: "https://github.com/me/go", Ref: "feature/x"},
+		"git:ssh://git@github.com/me/go@release-branch.go1.21/fix":  {URL: "ssh://git@github.com/me/go", Ref: "release-branch.go1.21/fix"},
+		"git:git@github.com:me/go@feature/x":                        {URL: "git@github.com:me/go", Ref: "feature/x"},
+		"git:/srv/go.git@refs/heads/dev/x":                          {URL: "/srv/go.git", Ref: "refs/heads/dev/x"},
+		"git:https://user@example.com/go.git":                       {URL: "https://user@example.com/go.git", Ref: "HEAD"},
+		"git:https://user@example.com/go.git@refs/changes/12/345/6": {URL: "https://user@example.com/go.git", Ref: "refs/changes/12/345/6"},
+	}
+
+	for s, expected := range tests {
+		if spec, ok := ParseSpec(s); !ok || spec != expected {
+			t.Errorf("%s: got %+v %v, expected %+v", s, spec, ok, expected)
+		}
+	}
+
+	for _, s := range []string{"go1.21.5", "git:--upload-pack=touch /tmp/evil", "git:/srv/go.git@--upload-pack=evil"} {
+		if _, ok := ParseSpec(s); ok {
+			t.Errorf("%s: parsed as git spec", s)
+		}
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+func TestFetchArchive(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	var src = t.TempDir()
+	git(t, src, "init", "--quiet")
+	if err := ioutil.WriteFile(filepath.Join(src, "README"), []byte("tip\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, src, "add", "README")
+	git(t, src, "-c", "user.name=gvm", "-c", "user.email=gvm@localhost", "commit", "--quiet", "-m", "tip")
+
+	var repo = filepath.Join(t.TempDir(), "go.git")
+	commit, err := Fetch(repo, Spec{URL: src, Ref: "HEAD"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commit) != 40 {
+		t.Fatalf("commit: got %q", commit)
+	}
+
+	// fetching again updates the existing clone
+	if again, err := Fetch(repo, Spec{URL: src, Ref: "HEAD"}, nil); err != nil || again != commit {
+		t.Fatalf("fetch again: got %q %v", again, err)
+	}
+
+	var dest = t.TempDir()
+	if err = Archive(repo, commit, dest); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dest, "README")); err != nil || string(data) != "tip\n" {
+		t.Fatalf("README: got %q %v", data, err)
+	}
+
+	// a url or ref is never read as an option
+	var evil = filepath.Join(t.TempDir(), "evil")
+	for _, spec := range []Spec{
+		{URL: src, Ref: "--upload-pack=touch " + evil},
+		{URL: "--upload-pack=touch " + evil, Ref: "HEAD"},
+	} {
+		if _, err = Fetch(filepath.Join(t.TempDir(), "go.git"), spec, nil); err == nil {
+			t.Errorf("%s: fetched", spec)
+		}
+		if _, err = Fetch(repo, spec, nil); err == nil {
+			t.Errorf("%s: fetched into a clone", spec)
+		}
+	}
+	if _, err = os.Stat(evil); !os.IsNotExist(err) {
+		t.Fatal("option injected into git")
+	}
+}
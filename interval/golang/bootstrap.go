@@ -0,0 +1,90 @@
+package golang
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+)
+
+var goversionRegexp = regexp.MustCompile(`(?m)^const Version = (\d+)`)
+
+// BootstrapMin returns the oldest go release able to build v. Releases
+// before go1.5 are built with the C toolchain and need none.
+//
+//	go1.5  - go1.19  go1.4
+//	go1.20 - go1.21  go1.17.13
+//	go1.22 - go1.23  go1.20.6
+//	go1.N, N >= 24   go1.M.6, M is N-2 rounded down to even
+func BootstrapMin(v GoVersion) (min GoVersion) {
+	var name string
+	switch {
+	case v.major == 1 && v.minor < 5:
+		return
+	case v.major == 1 && v.minor < 20:
+		name = "go1.4"
+	case v.major == 1 && v.minor < 22:
+		name = "go1.17.13"
+	case v.major == 1 && v.minor < 24:
+		name = "go1.20.6"
+	default:
+		name = fmt.Sprintf("go1.%d.6", (v.minor-2)/2*2)
+	}
+
+	min, _ = ParseVersion(name)
+	return
+}
+
+// Bootstrap returns the oldest stable of versions able to build version,
+// or "" if version needs no bootstrap.
+func Bootstrap(version string, versions []string) (string, error) {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	var min = BootstrapMin(v)
+	if min.IsZero() {
+		return "", nil
+	}
+
+	var oldest GoVersion
+	var name string
+	for _, version := range versions {
+		b, err := ParseVersion(version)
+		if err != nil || !b.IsStable() || b.Less(min) {
+			continue
+		}
+		if name == "" || b.Less(oldest) {
+			oldest, name = b, version
+		}
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("building %s needs %s or newer as bootstrap", version, min)
+	}
+
+	return name, nil
+}
+
+// TreeVersion returns the version of the go source tree root, from its
+// VERSION file or, in a development tree, the language version declared in
+// src/internal/goversion.
+func TreeVersion(root string) (string, error) {
+	if version, err := RootVersion(root); err == nil {
+		return version, nil
+	}
+
+	var filename = filepath.Join(root, "src", "internal", "goversion", "goversion.go")
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("%s: no VERSION file or goversion, no go tree", root)
+	}
+
+	match := goversionRegexp.FindSubmatch(data)
+	if match == nil {
+		return "", fmt.Errorf("%s: no version", filename)
+	}
+
+	return "go1." + string(match[1]), nil
+}
@@ -0,0 +1,50 @@
+package golang
+
+import "testing"
+
+func TestBootstrapMin(t *testing.T) {
+	var tests = map[string]string{
+		"go1.4":     "",
+		"go1.5":     "go1.4",
+		"go1.19.13": "go1.4",
+		"go1.20":    "go1.17.13",
+		"go1.21.5":  "go1.17.13",
+		"go1.22.0":  "go1.20.6",
+		"go1.23rc1": "go1.20.6",
+		"go1.24.1":  "go1.22.6",
+		"go1.25.0":  "go1.22.6",
+		"go1.26.0":  "go1.24.6",
+	}
+
+	for version, expected := range tests {
+		v, err := ParseVersion(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if min := BootstrapMin(v); min.String() != expected {
+			t.Errorf("%s: got %q, expected %q", version, min, expected)
+		}
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	var installed = []string{"go1.4", "go1.17.13", "go1.20.5", "go1.21rc2", "go1.21.5", "go1.22.6"}
+
+	var tests = map[string]string{
+		"go1.3":    "",
+		"go1.16":   "go1.4",
+		"go1.21.5": "go1.17.13",
+		"go1.23.0": "go1.21.5",
+		"go1.24.0": "go1.22.6",
+	}
+
+	for version, expected := range tests {
+		if name, err := Bootstrap(version, installed); err != nil || name != expected {
+			t.Errorf("%s: got %q %v, expected %q", version, name, err, expected)
+		}
+	}
+
+	if _, err := Bootstrap("go1.26.0", installed); err == nil {
+		t.Error("go1.26.0: expected missing bootstrap error")
+	}
+}
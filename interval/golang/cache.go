@@ -0,0 +1,98 @@
+package golang
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/files"
+)
+
+// Index is the version index of the mirrors, cached in a file. It is safe
+// for concurrent use, concurrent lookups wait for one load.
+type Index struct {
+	// File caches the fetched versions, empty disables the cache.
+	File string
+
+	// Mirrors are the mirror urls in fallback order, like
+	// https://golang.google.cn/dl/, default the official downloads.
+	Mirrors []string
+
+	// Offline uses only the cached versions.
+	Offline bool
+
+	// TTL is how long the cached versions are used before fetching them
+	// again.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	loaded  []Version
+	refresh bool
+}
+
+type cachedIndex struct {
+	Time     time.Time `json:"time"`
+	Versions []Version `json:"versions"`
+}
+
+// Refresh makes the next lookup fetch the versions ignoring the cache.
+func (idx *Index) Refresh() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.loaded, idx.refresh = nil, true
+}
+
+func (idx *Index) read() (cached cachedIndex, err error) {
+	if idx.File == "" {
+		return cached, errors.New("no index file")
+	}
+	err = files.ReadJSON(idx.File, &cached)
+	return
+}
+
+// load returns the version index, from the cache while it is younger
+// than TTL or when offline, and fetched from the mirrors otherwise.
+// A stale cache is used when fetching fails.
+func (idx *Index) load() ([]Version, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.loaded != nil {
+		return idx.loaded, nil
+	}
+
+	cached, cacheErr := idx.read()
+	if idx.Offline {
+		if cacheErr != nil {
+			return nil, cacheErr
+		}
+		idx.loaded = cached.Versions
+		return idx.loaded, nil
+	}
+
+	if cacheErr == nil && !idx.refresh && time.Since(cached.Time) < idx.TTL {
+		idx.loaded = cached.Versions
+		return idx.loaded, nil
+	}
+
+	versions, err := Fetch(idx.Sources()...)
+	if err != nil {
+		if cacheErr == nil && len(cached.Versions) > 0 {
+			debug.Println("golang: fetch error, using cached index:", err.Error())
+			idx.loaded = cached.Versions
+			return idx.loaded, nil
+		}
+		return nil, err
+	}
+
+	if idx.File != "" {
+		if err = files.WriteJSON(idx.File, cachedIndex{Time: time.Now(), Versions: versions}, 0644); err != nil {
+			debug.Println("golang: write index error:", err.Error())
+		}
+	}
+
+	idx.loaded = versions
+	return idx.loaded, nil
+}
@@ -0,0 +1,198 @@
+package golang
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a version query:
+//
+//	go1.21.3, 1.21.3     exact version
+//	latest               newest version, including pre-releases
+//	stable               newest stable version
+//	1.21, go1.20.x       newest patch of a minor version
+//	~1.20, ~1.20.3       at least the version, within the same minor
+//	^1.20                at least the version, within the same major
+//	>=1.19 <1.22         comparisons, separated by space or comma
+//
+// Only stable versions match unless the query names a pre-release.
+type Constraint struct {
+	query string
+	exact GoVersion
+	terms []term
+	pre   bool
+}
+
+type term struct {
+	op string
+	v  GoVersion
+}
+
+var minorRegexp = regexp.MustCompile(`^(?:go)?(\d+)\.(\d+)(?:\.[x*])?$`)
+
+var opRegexp = regexp.MustCompile(`^(>=|<=|!=|==|=|>|<|~|\^)?(\S+)$`)
+
+var spaceRegexp = regexp.MustCompile(`(>=|<=|!=|==|=|>|<|~|\^)\s+`)
+
+// release returns v without the language version meaning, so go1.21 is
+// treated like go1.21.0 in comparisons.
+func (v GoVersion) release() GoVersion {
+	v.lang = false
+	return v
+}
+
+func isRelease(name string) bool {
+	v, err := ParseVersion(name)
+	return err == nil && strings.HasPrefix(name, "go") && !v.lang
+}
+
+// ParseConstraint parses a version query.
+func ParseConstraint(query string) (c Constraint, err error) {
+	c.query = strings.TrimSpace(query)
+
+	switch strings.ToLower(c.query) {
+	case "":
+		return c, fmt.Errorf("empty version")
+	case "latest":
+		c.pre = true
+		return
+	case "stable":
+		return
+	}
+
+	// go1.20 is an exact release name, while 1.20 and go1.21 (a language
+	// version) select the newest patch.
+	if match := minorRegexp.FindStringSubmatch(c.query); match != nil && !isRelease(c.query) {
+		major, _ := strconv.Atoi(match[1])
+		minor, _ := strconv.Atoi(match[2])
+		c.terms = append(c.terms, term{op: "minor", v: GoVersion{major: major, minor: minor}})
+		return
+	}
+
+	if c.exact, err = ParseVersion(c.query); err == nil {
+		return
+	}
+
+	// comparisons like ">= 1.19, <1.22"
+	query = spaceRegexp.ReplaceAllString(c.query, "$1")
+	fields := strings.FieldsFunc(query, func(r rune) bool { return r == ',' || r == ' ' })
+
+	for _, field := range fields {
+		match := opRegexp.FindStringSubmatch(field)
+		if match == nil {
+			return c, fmt.Errorf("invalid version constraint: %q", c.query)
+		}
+
+		v, err := ParseVersion(strings.TrimSuffix(strings.TrimSuffix(match[2], ".x"), ".*"))
+		if err != nil {
+			return c, fmt.Errorf("invalid version constraint: %q", c.query)
+		}
+		if v.pre != "" {
+			c.pre = true
+		}
+
+		var op = match[1]
+		switch op {
+		case "", "=", "==":
+			op = "=="
+		}
+		c.terms = append(c.terms, term{op: op, v: v.release()})
+	}
+
+	return c, nil
+}
+
+func (t term) check(v GoVersion) bool {
+	switch t.op {
+	case "minor":
+		return v.major == t.v.major && v.minor == t.v.minor
+	case "~":
+		return v.major == t.v.major && v.minor == t.v.minor && v.Compare(t.v) >= 0
+	case "^":
+		return v.major == t.v.major && v.Compare(t.v) >= 0
+	}
+
+	c := v.Compare(t.v)
+	switch t.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+
+	return false
+}
+
+// IsExact reports whether the query names a single version.
+func (c Constraint) IsExact() bool {
+	return !c.exact.IsZero()
+}
+
+// Check reports whether v satisfies the constraint.
+func (c Constraint) Check(v GoVersion) bool {
+	if c.IsExact() {
+		return v.Compare(c.exact) == 0 && v.lang == c.exact.lang
+	}
+
+	if v.lang || (!c.pre && !v.IsStable()) {
+		return false
+	}
+
+	for _, t := range c.terms {
+		if !t.check(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c Constraint) String() string {
+	return c.query
+}
+
+// Resolve returns the newest of versions satisfying query. An exact
+// version resolves to itself, even if it is not in versions.
+func Resolve(query string, versions []string) (string, error) {
+	c, err := ParseConstraint(query)
+	if err != nil {
+		return "", err
+	}
+
+	if c.IsExact() {
+		for _, version := range versions {
+			if v, err := ParseVersion(version); err == nil && c.Check(v) {
+				return version, nil
+			}
+		}
+		return c.exact.String(), nil
+	}
+
+	var newest GoVersion
+	var name string
+	for _, version := range versions {
+		v, err := ParseVersion(version)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if newest.IsZero() || newest.Less(v) {
+			newest, name = v, version
+		}
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("no go version matches %q", query)
+	}
+
+	return name, nil
+}
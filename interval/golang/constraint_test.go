@@ -0,0 +1,57 @@
+package golang
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	var versions = []string{
+		"go1.9.2", "go1.19", "go1.19.13", "go1.20", "go1.20.3", "go1.20.14",
+		"go1.21rc2", "go1.21.0", "go1.21.5", "go1.22rc1",
+	}
+
+	var tests = []struct {
+		query   string
+		version string
+		err     bool
+	}{
+		{query: "latest", version: "go1.22rc1"},
+		{query: "stable", version: "go1.21.5"},
+		{query: "go1.9.2", version: "go1.9.2"},
+		{query: "1.9.2", version: "go1.9.2"},
+		{query: "go1.20", version: "go1.20"},
+		{query: "1.20", version: "go1.20.14"},
+		{query: "go1.20.x", version: "go1.20.14"},
+		{query: "1.21", version: "go1.21.5"},
+		{query: "go1.21", version: "go1.21.5"},
+		{query: "go1.21rc2", version: "go1.21rc2"},
+		{query: "go1.18.1", version: "go1.18.1"},
+		{query: "~1.20", version: "go1.20.14"},
+		{query: "~1.20.3", version: "go1.20.14"},
+		{query: "^1.19", version: "go1.21.5"},
+		{query: ">=1.19 <1.21", version: "go1.20.14"},
+		{query: ">= 1.19, < 1.20", version: "go1.19.13"},
+		{query: "<=1.20.3", version: "go1.20.3"},
+		{query: ">1.21", version: "go1.21.5"},
+		{query: ">=1.22rc1", version: "go1.22rc1"},
+		{query: ">=1.23", err: true},
+		{query: "1.17", err: true},
+		{query: "foo", err: true},
+		{query: "", err: true},
+	}
+
+	for _, test := range tests {
+		version, err := Resolve(test.query, versions)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got %s", test.query, version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.query, err)
+			continue
+		}
+		if version != test.version {
+			t.Errorf("%q: got %s, expected %s", test.query, version, test.version)
+		}
+	}
+}
@@ -6,26 +6,33 @@ import (
 	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/cheggaaa/pb/v3"
 	"github.com/zooyer/gvm/interval/conf"
 	"github.com/zooyer/gvm/interval/debug"
 	"github.com/zooyer/gvm/interval/utils"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 )
 
+// Version is a downloadable file of a go release. OS and Arch are in GOOS
+// and GOARCH form, Kind is one of "archive", "installer" or "source".
 type Version struct {
-	Name    string
-	Version string
-	URL     string
-	Kind    string
-	OS      string
-	Arch    string
-	Size    string
-	Sha256  string
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	Kind    string `json:"kind"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Size    int64  `json:"size"`
+	Sha256  string `json:"sha256"`
+	Stable  bool   `json:"stable"`
 }
 
 type Filter func(version Version) bool
@@ -58,20 +65,20 @@ var home = map[string]string{
 	"windows": "C:\\Program Files\\go",
 }
 
-var decoder = map[string]func(filename, dir string) error{
-	"darwin":  utils.Untargz,
-	"linux":   utils.Untargz,
-	"windows": utils.Unzip,
+var decoder = map[string]func(filename, dir string, bar *pb.ProgressBar) error{
+	"tar.gz": utils.Untargz,
+	"tgz":    utils.Untargz,
+	"zip":    utils.Unzip,
 }
 
 var defaultFilter = func(version Version) bool {
-	if version.Kind != "Archive" {
+	if version.Kind != "archive" {
 		return false
 	}
-	if os[version.OS] != runtime.GOOS {
+	if version.OS != runtime.GOOS {
 		return false
 	}
-	if arch[version.Arch] != runtime.GOARCH {
+	if version.Arch != runtime.GOARCH {
 		return false
 	}
 	return true
@@ -98,6 +105,17 @@ var defaultVersions = []string{
 	"go1.20",
 }
 
+// Mirror is a location serving the version listing and the archives.
+type Mirror struct {
+	List     string
+	Download string
+}
+
+var defaultMirror = Mirror{
+	List:     "https://golang.org/dl",
+	Download: "https://dl.google.com/go/",
+}
+
 var client = http.Client{
 	Transport: &http.Transport{
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -109,18 +127,71 @@ var client = http.Client{
 	Timeout:       conf.Timeout.Duration() * 2 / 3,
 }
 
-func getHTML() (html []byte, err error) {
-	res, err := client.Get("https://golang.org/dl")
-	if err != nil {
-		return
+// ParseMirrors splits a comma separated list of mirror urls.
+func ParseMirrors(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' '
+	})
+}
+
+// mirrors returns the mirrors of idx in fallback order. A mirror url serves
+// the version listing at its root and the archives below it, like
+// https://golang.google.cn/dl/.
+func (idx *Index) mirrors() []Mirror {
+	var mirrors []Mirror
+	for _, url := range idx.Mirrors {
+		mirrors = append(mirrors, Mirror{
+			List:     url,
+			Download: strings.TrimSuffix(url, "/") + "/",
+		})
+	}
+
+	if len(mirrors) == 0 {
+		mirrors = append(mirrors, defaultMirror)
+	}
+
+	return mirrors
+}
+
+// URLs returns the archive urls of version in mirror fallback order.
+func (idx *Index) URLs(version string) []string {
+	return idx.FileURLs(Filename(version))
+}
+
+// FileURLs returns the urls of the release file name in mirror fallback order.
+func (idx *Index) FileURLs(name string) []string {
+	var urls []string
+	for _, mirror := range idx.mirrors() {
+		urls = append(urls, mirror.Download+name)
 	}
-	defer res.Body.Close()
+	return urls
+}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, errors.New(res.Status)
+// normalize maps a display name of the download page to its GOOS/GOARCH name.
+func normalize(names map[string]string, name string) string {
+	name = strings.TrimSpace(name)
+	if n, exists := names[name]; exists {
+		return n
 	}
+	return strings.ToLower(name)
+}
 
-	return ioutil.ReadAll(res.Body)
+// parseSize parses a size like "63MB" of the download page.
+func parseSize(size string) int64 {
+	size = strings.TrimSpace(size)
+	var unit int64 = 1
+	for i, u := range []string{"KB", "MB", "GB"} {
+		if strings.HasSuffix(size, u) {
+			unit = 1 << (10 * (i + 1))
+			size = strings.TrimSuffix(size, u)
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(size), 64)
+	if err != nil {
+		return 0
+	}
+	return int64(n * float64(unit))
 }
 
 func parse(html []byte) (versions []Version, err error) {
@@ -139,26 +210,18 @@ func parse(html []byte) (versions []Version, err error) {
 		node := td.Find("a")
 		uri, _ := node.Attr("href")
 		name := node.Text()
-		field := strings.Split(name, ".")
-
-		// 过滤掉1.9以前的版本
-		if len(field[1]) == 1 && field[1][0] < '9' {
-			//return
-		}
-
-		version := strings.Join(field[:3], ".")
-		if c := field[2][0]; c < '0' || c > '9' {
-			if index := strings.Index(field[1], "-"); index >= 0 {
-				field[1] = field[1][:index]
-			}
-			version = strings.Join(field[:2], ".")
+		version := versionOf(name)
+		if version == "" {
+			debug.Println("golang: parse unknown file:", name)
+			return
 		}
+		v, _ := ParseVersion(version)
 
-		kind := td.Eq(1).Text()
-		os := td.Eq(2).Text()
-		arch := td.Eq(3).Text()
-		size := td.Eq(4).Text()
-		sha256 := td.Eq(5).Text()
+		kind := strings.ToLower(strings.TrimSpace(td.Eq(1).Text()))
+		os := normalize(os, td.Eq(2).Text())
+		arch := normalize(arch, td.Eq(3).Text())
+		size := parseSize(td.Eq(4).Text())
+		sha256 := strings.TrimSpace(td.Eq(5).Text())
 
 		versions = append(versions, Version{
 			Name:    name,
@@ -169,19 +232,23 @@ func parse(html []byte) (versions []Version, err error) {
 			Arch:    arch,
 			Size:    size,
 			Sha256:  sha256,
+			Stable:  v.IsStable(),
 		})
 	})
 
 	return
 }
 
-func GoVersions(filter ...Filter) (versions []Version) {
+// Versions returns the files of the go releases passing every filter.
+func (idx *Index) Versions(filter ...Filter) (versions []Version) {
 	defer func() {
 		var vs = make([]Version, 0, len(versions))
 		for _, v := range versions {
 			var ok = true
 			for _, filter := range filter {
-				ok = filter(v)
+				if ok = filter(v); !ok {
+					break
+				}
 			}
 			if ok {
 				vs = append(vs, v)
@@ -190,54 +257,81 @@ func GoVersions(filter ...Filter) (versions []Version) {
 		versions = vs
 	}()
 
-	html, err := getHTML()
-	if err != nil {
-		debug.Println("golang: get html error:", err.Error())
-		return
-	}
-
-	versions, err = parse(html)
+	versions, err := idx.load()
 	if err != nil {
-		debug.Println("golang: parse error:", err.Error())
+		debug.Println("golang: versions error:", err.Error())
 		return
 	}
 
 	return versions
 }
 
-func GoVersionsList() []string {
+// Available returns the names of the versions with an archive for the
+// current os and arch, from oldest to newest.
+func (idx *Index) Available() []string {
 	var m = make(map[string]bool)
 	var versions []string
-	for _, v := range GoVersions(defaultFilter) {
+	for _, v := range idx.Versions(defaultFilter) {
+		if _, err := ParseVersion(v.Version); err != nil {
+			debug.Println("golang: skip version:", err.Error())
+			continue
+		}
 		if !m[v.Version] {
 			m[v.Version] = true
 			versions = append(versions, v.Version)
 		}
 	}
 
-	if len(versions) == 0 {
+	Sort(versions)
+
+	return versions
+}
+
+// List returns the available versions, or the minor versions known to gvm
+// if the index can not be loaded online.
+func (idx *Index) List() []string {
+	var versions = idx.Available()
+	if len(versions) == 0 && !idx.Offline {
 		versions = defaultVersions
 	}
 
-	sort.Slice(versions, func(i, j int) bool {
-		a := strings.Split(versions[i], ".")
-		b := strings.Split(versions[j], ".")
-		var length = len(a)
-		if len(a) > len(b) {
-			length = len(b)
-		}
-		for i := 0; i < length; i++ {
-			if len(a[i]) != len(b[i]) {
-				return len(a[i]) < len(b[i])
-			}
-			if a[i] != b[i] {
-				return a[i] < b[i]
-			}
+	return versions
+}
+
+// Sort sorts version names from oldest to newest, names which are not go
+// versions sort last.
+func Sort(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, errA := ParseVersion(versions[i])
+		b, errB := ParseVersion(versions[j])
+		switch {
+		case errA != nil && errB != nil:
+			return versions[i] < versions[j]
+		case errA != nil || errB != nil:
+			return errB != nil
 		}
-		return len(a) < len(b)
+		return a.Less(b)
 	})
+}
 
-	return versions
+// Find returns the archive of version for the current os and arch.
+func (idx *Index) Find(version string) (Version, error) {
+	for _, v := range idx.Versions(defaultFilter) {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return Version{}, fmt.Errorf("%s not found for %s/%s", version, runtime.GOOS, runtime.GOARCH)
+}
+
+// FindSource returns the source archive of version.
+func (idx *Index) FindSource(version string) (Version, error) {
+	for _, v := range idx.Versions() {
+		if v.Kind == "source" && v.Version == version {
+			return v, nil
+		}
+	}
+	return Version{}, fmt.Errorf("%s source not found", version)
 }
 
 func Suffix() string {
@@ -248,11 +342,45 @@ func Filename(version string) string {
 	return fmt.Sprintf("%s.%s-%s.%s", version, runtime.GOOS, runtime.GOARCH, suffix[runtime.GOOS])
 }
 
-func Decode(filename, dir string) error {
-	if decode, exists := decoder[runtime.GOOS]; exists {
-		return decode(filename, dir)
+// Decode extracts the archive filename into dir by its suffix, showing the
+// progress on bar or a new bar if bar is nil.
+func Decode(filename, dir string, bar *pb.ProgressBar) error {
+	for suffix, decode := range decoder {
+		if strings.HasSuffix(strings.ToLower(filename), "."+suffix) {
+			return decode(filename, dir, bar)
+		}
+	}
+	return errors.New("not support archive: " + filename)
+}
+
+// RootVersion returns the version of the go tree root, from the first line
+// of its VERSION file.
+func RootVersion(root string) (version string, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, "VERSION"))
+	if err != nil {
+		return
+	}
+
+	version = strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	if _, err = ParseVersion(version); err != nil {
+		return "", fmt.Errorf("%s: %w", filepath.Join(root, "VERSION"), err)
+	}
+
+	return
+}
+
+// CanStream reports whether the archive of the current os can be unpacked
+// while it is downloaded, a zip needs random access.
+func CanStream() bool {
+	return suffix[runtime.GOOS] == "tar.gz"
+}
+
+// DecodeReader extracts the archive stream r into dir.
+func DecodeReader(r io.Reader, dir string) error {
+	if !CanStream() {
+		return errors.New("not support streaming a " + suffix[runtime.GOOS] + " archive")
 	}
-	return errors.New("not support os: " + runtime.GOOS)
+	return utils.UntargzReader(r, dir)
 }
 
 func DefaultGoHome() string {
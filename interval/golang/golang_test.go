@@ -1,7 +1,223 @@
 package golang
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zooyer/gvm/interval/files"
+)
 
 func TestGoVersions(t *testing.T) {
-	t.Log(GoVersionsList())
+	t.Log(new(Index).List())
+}
+
+const feed = `[
+ {
+  "version": "go1.21.0",
+  "stable": true,
+  "files": [
+   {
+    "filename": "go1.21.0.linux-amd64.tar.gz",
+    "os": "linux",
+    "arch": "amd64",
+    "version": "go1.21.0",
+    "sha256": "d0398903a16ba2232b389fb31032ddf57cac34efda306a0eebac34f0965a0742",
+    "size": 66615271,
+    "kind": "archive"
+   },
+   {
+    "filename": "go1.21.0.src.tar.gz",
+    "os": "",
+    "arch": "",
+    "version": "go1.21.0",
+    "sha256": "818d46ede85682dd551ad378ef37a4d247006f12ec59b5b755601d2ce114369a",
+    "size": 26956889,
+    "kind": "source"
+   }
+  ]
+ },
+ {
+  "version": "go1.21rc2",
+  "stable": false,
+  "files": [
+   {
+    "filename": "go1.21rc2.windows-386.zip",
+    "os": "windows",
+    "arch": "386",
+    "version": "go1.21rc2",
+    "sha256": "ab0e8ef5a5e89f2a2b8d3ec5b7ae8d6f2c1c0f26d2c0ab3e18c0f7c0e1f1c2a3",
+    "size": 70000000,
+    "kind": "archive"
+   }
+  ]
+ }
+]`
+
+const page = `<html><body>
+<div class="toggleVisible" id="go1.21.0">
+<div class="expanded">
+<table class="downloadtable">
+<thead><tr><th>File name</th><th>Kind</th><th>OS</th><th>Arch</th><th>Size</th><th>SHA256 Checksum</th></tr></thead>
+<tr>
+ <td class="filename"><a class="download" href="/dl/go1.21.0.linux-amd64.tar.gz">go1.21.0.linux-amd64.tar.gz</a></td>
+ <td>Archive</td><td>Linux</td><td>x86-64</td><td>64MB</td>
+ <td><tt>d0398903a16ba2232b389fb31032ddf57cac34efda306a0eebac34f0965a0742</tt></td>
+</tr>
+<tr>
+ <td class="filename"><a class="download" href="/dl/go1.21.0.darwin-arm64.pkg">go1.21.0.darwin-arm64.pkg</a></td>
+ <td>Installer</td><td>macOS</td><td>ARMv8</td><td>63MB</td>
+ <td><tt>b8e1ad2eb8b2b5a6e2d8f6dbb1b1b0e7c59d6a5b2f6c9d8e7f6a5b4c3d2e1f0a</tt></td>
+</tr>
+</table>
+</div>
+</div>
+</body></html>`
+
+func serve(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestJSONSource(t *testing.T) {
+	server := serve(http.StatusOK, feed)
+	defer server.Close()
+
+	versions, err := JSONSource(server.URL + "/dl/?mode=json&include=all").Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(versions))
+	}
+
+	v := versions[0]
+	if v.Version != "go1.21.0" || v.OS != "linux" || v.Arch != "amd64" || v.Kind != "archive" || !v.Stable || v.Size != 66615271 {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+	if v.URL != server.URL+"/dl/go1.21.0.linux-amd64.tar.gz" {
+		t.Fatalf("unexpected url: %s", v.URL)
+	}
+	if versions[2].Stable {
+		t.Fatalf("go1.21rc2 must not be stable")
+	}
+}
+
+func TestHTMLSource(t *testing.T) {
+	server := serve(http.StatusOK, page)
+	defer server.Close()
+
+	versions, err := HTMLSource(server.URL + "/dl/").Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(versions))
+	}
+
+	v := versions[0]
+	if v.Version != "go1.21.0" || v.OS != "linux" || v.Arch != "amd64" || v.Kind != "archive" || v.Size != 64<<20 {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+	if v.Sha256 != "d0398903a16ba2232b389fb31032ddf57cac34efda306a0eebac34f0965a0742" {
+		t.Fatalf("unexpected sha256: %s", v.Sha256)
+	}
+	if v.URL != server.URL+"/dl/go1.21.0.linux-amd64.tar.gz" {
+		t.Fatalf("unexpected url: %s", v.URL)
+	}
+	if v = versions[1]; v.OS != "darwin" || v.Arch != "arm64" || v.Kind != "installer" {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	broken := serve(http.StatusInternalServerError, "")
+	defer broken.Close()
+	layout := serve(http.StatusOK, "<html></html>")
+	defer layout.Close()
+	html := serve(http.StatusOK, page)
+	defer html.Close()
+
+	versions, err := Fetch(JSONSource(broken.URL), HTMLSource(layout.URL), HTMLSource(html.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected the html fallback, got %d versions", len(versions))
+	}
+
+	if _, err = Fetch(JSONSource(broken.URL), HTMLSource(layout.URL)); err == nil {
+		t.Fatal("expected an error without any versions")
+	}
+}
+
+func TestIndexCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	var idx = &Index{
+		File:    filepath.Join(t.TempDir(), "versions.json"),
+		Mirrors: []string{server.URL + "/dl/"},
+		TTL:     24 * time.Hour,
+	}
+
+	// fetched and cached
+	if versions, err := idx.load(); err != nil || len(versions) != 3 {
+		t.Fatalf("load: %d versions, %v", len(versions), err)
+	}
+
+	// fresh cache
+	idx.loaded = nil
+	if _, err := idx.load(); err != nil || atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("expected cached versions, %d requests, %v", requests, err)
+	}
+
+	// stale cache
+	var cached cachedIndex
+	if err := files.ReadJSON(idx.File, &cached); err != nil {
+		t.Fatal(err)
+	}
+	cached.Time = time.Now().Add(-2 * idx.TTL)
+	if err := files.WriteJSON(idx.File, cached, 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx.loaded = nil
+	if _, err := idx.load(); err != nil || atomic.LoadInt32(&requests) != 2 {
+		t.Fatalf("expected refetch, %d requests, %v", requests, err)
+	}
+
+	// concurrent lookups fetch once
+	idx.Refresh()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if versions := idx.Versions(); len(versions) != 3 {
+				t.Errorf("concurrent: %d versions", len(versions))
+			}
+		}()
+	}
+	wg.Wait()
+	if requests := atomic.LoadInt32(&requests); requests != 3 {
+		t.Fatalf("expected one fetch, %d requests", requests)
+	}
+
+	// offline
+	server.Close()
+	idx.Offline = true
+	idx.Refresh()
+	if versions, err := idx.load(); err != nil || len(versions) != 3 {
+		t.Fatalf("offline: %d versions, %v", len(versions), err)
+	}
 }
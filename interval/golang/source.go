@@ -0,0 +1,147 @@
+package golang
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// VersionSource lists the files of the go releases.
+type VersionSource interface {
+	Versions() ([]Version, error)
+}
+
+// JSONSource is the url of the official release feed, like
+// https://golang.org/dl/?mode=json&include=all.
+type JSONSource string
+
+// HTMLSource is the url of a download page, its file tables are scraped.
+type HTMLSource string
+
+type release struct {
+	Version string `json:"version"`
+	Stable  bool   `json:"stable"`
+	Files   []struct {
+		Filename string `json:"filename"`
+		OS       string `json:"os"`
+		Arch     string `json:"arch"`
+		Version  string `json:"version"`
+		Sha256   string `json:"sha256"`
+		Size     int64  `json:"size"`
+		Kind     string `json:"kind"`
+	} `json:"files"`
+}
+
+func get(url string) (data []byte, err error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, res.Status)
+	}
+
+	return ioutil.ReadAll(res.Body)
+}
+
+// resolve returns ref relative to the base url.
+func resolve(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+func (s JSONSource) Versions() (versions []Version, err error) {
+	data, err := get(string(s))
+	if err != nil {
+		return
+	}
+
+	var releases []release
+	if err = json.Unmarshal(data, &releases); err != nil {
+		return nil, fmt.Errorf("%s: %w", s, err)
+	}
+
+	for _, r := range releases {
+		for _, f := range r.Files {
+			var version = f.Version
+			if version == "" {
+				version = r.Version
+			}
+			versions = append(versions, Version{
+				Name:    f.Filename,
+				Version: version,
+				URL:     resolve(string(s), f.Filename),
+				Kind:    f.Kind,
+				OS:      f.OS,
+				Arch:    f.Arch,
+				Size:    f.Size,
+				Sha256:  f.Sha256,
+				Stable:  r.Stable,
+			})
+		}
+	}
+
+	return
+}
+
+func (s HTMLSource) Versions() (versions []Version, err error) {
+	html, err := get(string(s))
+	if err != nil {
+		return
+	}
+
+	if versions, err = parse(html); err != nil {
+		return
+	}
+
+	for i := range versions {
+		versions[i].URL = resolve(string(s), versions[i].URL)
+	}
+
+	return
+}
+
+// Sources returns the version sources of the mirrors in fallback order,
+// the release feed of a mirror is preferred over scraping its page.
+func (idx *Index) Sources() []VersionSource {
+	var sources []VersionSource
+	for _, mirror := range idx.mirrors() {
+		base := strings.TrimSuffix(mirror.List, "/") + "/"
+		sources = append(sources, JSONSource(base+"?mode=json&include=all"), HTMLSource(base))
+	}
+	return sources
+}
+
+// Fetch returns the versions of the first source that lists any.
+func Fetch(sources ...VersionSource) (versions []Version, err error) {
+	var errs []string
+	for _, source := range sources {
+		if versions, err = source.Versions(); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if len(versions) > 0 {
+			return versions, nil
+		}
+		errs = append(errs, fmt.Sprintf("%v: no versions found", source))
+	}
+
+	if len(errs) == 0 {
+		return nil, errors.New("no version source")
+	}
+
+	return nil, errors.New(strings.Join(errs, "; "))
+}
@@ -0,0 +1,172 @@
+package golang
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// GoVersion is a go release name, like go1, go1.9.2, go1.21rc1 or go1.21.0.
+//
+// Before go1.21 a release had no patch number, go1.20 is the same release
+// as go1.20.0. Since go1.21 the first release is go1.21.0 and go1.21 names
+// the language version, which sorts before all go1.21 pre-releases.
+type GoVersion struct {
+	name   string
+	major  int
+	minor  int
+	patch  int
+	pre    string
+	preNum int
+	lang   bool
+}
+
+var versionRegexp = regexp.MustCompile(`^go(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:(beta|rc)(\d+))?$`)
+
+// nameRegexp matches the version prefix of a file name like
+// go1.21.0.linux-amd64.tar.gz or go1.4-bootstrap-20171003.tar.gz.
+var nameRegexp = regexp.MustCompile(`^go\d+(?:\.\d+)?(?:\.\d+)?(?:(?:beta|rc)\d+)?`)
+
+// ParseVersion parses a go release name, the "go" prefix is optional.
+func ParseVersion(name string) (v GoVersion, err error) {
+	var s = name
+	if len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+		s = "go" + s
+	}
+
+	match := versionRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return v, fmt.Errorf("invalid go version: %q", name)
+	}
+
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	v = GoVersion{
+		name:   s,
+		major:  atoi(match[1]),
+		minor:  atoi(match[2]),
+		patch:  atoi(match[3]),
+		pre:    match[4],
+		preNum: atoi(match[5]),
+	}
+	v.lang = v.major == 1 && v.minor >= 21 && match[3] == "" && v.pre == ""
+
+	return v, nil
+}
+
+// versionOf returns the version name of a release file name.
+func versionOf(filename string) string {
+	return nameRegexp.FindString(filename)
+}
+
+func (v GoVersion) Major() int {
+	return v.major
+}
+
+func (v GoVersion) Minor() int {
+	return v.minor
+}
+
+func (v GoVersion) Patch() int {
+	return v.patch
+}
+
+// Prerelease returns the pre-release suffix like "rc1", or "".
+func (v GoVersion) Prerelease() string {
+	if v.pre == "" {
+		return ""
+	}
+	return v.pre + strconv.Itoa(v.preNum)
+}
+
+// IsStable reports whether v is a release, not a beta, rc or language version.
+func (v GoVersion) IsStable() bool {
+	return v.pre == "" && !v.lang
+}
+
+// IsZero reports whether v is the zero value.
+func (v GoVersion) IsZero() bool {
+	return v.name == ""
+}
+
+func (v GoVersion) String() string {
+	return v.name
+}
+
+func cmp(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// rank orders the kinds of a version within the same patch.
+func (v GoVersion) rank() int {
+	switch {
+	case v.lang:
+		return 0
+	case v.pre == "beta":
+		return 1
+	case v.pre == "rc":
+		return 2
+	}
+	return 3
+}
+
+// Compare returns -1, 0 or 1 if v is older, the same or newer than o.
+func (v GoVersion) Compare(o GoVersion) int {
+	if c := cmp(v.major, o.major); c != 0 {
+		return c
+	}
+	if c := cmp(v.minor, o.minor); c != 0 {
+		return c
+	}
+	if v.lang || o.lang {
+		return cmp(v.rank(), o.rank())
+	}
+	if c := cmp(v.patch, o.patch); c != 0 {
+		return c
+	}
+	if c := cmp(v.rank(), o.rank()); c != 0 {
+		return c
+	}
+	return cmp(v.preNum, o.preNum)
+}
+
+// Less reports whether v is older than o.
+func (v GoVersion) Less(o GoVersion) bool {
+	return v.Compare(o) < 0
+}
+
+// NewestPatches returns the newest n versions of every minor version in
+// versions, from oldest to newest. Names which are not go versions are
+// skipped.
+func NewestPatches(versions []string, n int) (newest []string) {
+	var minors = make(map[[2]int][]string)
+	for _, name := range versions {
+		v, err := ParseVersion(name)
+		if err != nil {
+			continue
+		}
+		var minor = [2]int{v.Major(), v.Minor()}
+		minors[minor] = append(minors[minor], name)
+	}
+
+	for _, names := range minors {
+		Sort(names)
+		if len(names) > n {
+			names = names[len(names)-n:]
+		}
+		newest = append(newest, names...)
+	}
+
+	Sort(newest)
+
+	return
+}
@@ -0,0 +1,138 @@
+package golang
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	var tests = []struct {
+		name   string
+		major  int
+		minor  int
+		patch  int
+		pre    string
+		stable bool
+		err    bool
+	}{
+		{name: "go1", major: 1, stable: true},
+		{name: "go1.0.3", major: 1, minor: 0, patch: 3, stable: true},
+		{name: "go1.2rc2", major: 1, minor: 2, pre: "rc2"},
+		{name: "go1.9.2", major: 1, minor: 9, patch: 2, stable: true},
+		{name: "go1.9.2rc2", major: 1, minor: 9, patch: 2, pre: "rc2"},
+		{name: "go1.20", major: 1, minor: 20, stable: true},
+		{name: "go1.21", major: 1, minor: 21},
+		{name: "go1.21beta1", major: 1, minor: 21, pre: "beta1"},
+		{name: "go1.21rc1", major: 1, minor: 21, pre: "rc1"},
+		{name: "go1.21.0", major: 1, minor: 21, stable: true},
+		{name: "1.22.3", major: 1, minor: 22, patch: 3, stable: true},
+		{name: "go1.21.x", err: true},
+		{name: "go", err: true},
+		{name: "tip", err: true},
+		{name: "go1.21rc", err: true},
+	}
+
+	for _, test := range tests {
+		v, err := ParseVersion(test.name)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if v.Major() != test.major || v.Minor() != test.minor || v.Patch() != test.patch || v.Prerelease() != test.pre {
+			t.Errorf("%s: got %d.%d.%d%s", test.name, v.Major(), v.Minor(), v.Patch(), v.Prerelease())
+		}
+		if v.IsStable() != test.stable {
+			t.Errorf("%s: stable %v, expected %v", test.name, v.IsStable(), test.stable)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	var tests = []struct {
+		a, b string
+		cmp  int
+	}{
+		{"go1", "go1.0.0", 0},
+		{"go1", "go1.0.1", -1},
+		{"go1.2rc2", "go1.2", -1},
+		{"go1.9", "go1.9.2rc2", -1},
+		{"go1.9.2rc2", "go1.9.2", -1},
+		{"go1.9.2", "go1.10", -1},
+		{"go1.20rc1", "go1.20", -1},
+		{"go1.20", "go1.20.0", 0},
+		{"go1.20.14", "go1.21", -1},
+		{"go1.21", "go1.21beta1", -1},
+		{"go1.21beta1", "go1.21rc1", -1},
+		{"go1.21rc1", "go1.21rc2", -1},
+		{"go1.21rc2", "go1.21.0", -1},
+		{"go1.21.0", "go1.21.1", -1},
+		{"go1.21.10", "go1.21.9", 1},
+		{"go1.22.0", "go1.22.0", 0},
+	}
+
+	for _, test := range tests {
+		a, err := ParseVersion(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseVersion(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := a.Compare(b); c != test.cmp {
+			t.Errorf("compare(%s, %s) = %d, expected %d", test.a, test.b, c, test.cmp)
+		}
+		if c := b.Compare(a); c != -test.cmp {
+			t.Errorf("compare(%s, %s) = %d, expected %d", test.b, test.a, c, -test.cmp)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	var versions = []string{"go1.21.0", "tip-abc", "go1.9.2", "go1.21rc1", "go1.10", "go1.9.2rc2", "go1.20"}
+	Sort(versions)
+
+	var expected = []string{"go1.9.2rc2", "go1.9.2", "go1.10", "go1.20", "go1.21rc1", "go1.21.0", "tip-abc"}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Fatalf("got %v, expected %v", versions, expected)
+	}
+}
+
+func TestNewestPatches(t *testing.T) {
+	var versions = []string{"go1.21.1", "go1.20.3", "gotip-0123456789ab", "go1.21.3", "go1.20.1", "go1.21.2", "go1.22rc1", "go1.20.2"}
+
+	var tests = map[int][]string{
+		0: nil,
+		1: {"go1.20.3", "go1.21.3", "go1.22rc1"},
+		2: {"go1.20.2", "go1.20.3", "go1.21.2", "go1.21.3", "go1.22rc1"},
+	}
+
+	for n, expected := range tests {
+		if newest := NewestPatches(versions, n); !reflect.DeepEqual(newest, expected) {
+			t.Errorf("%d: got %v, expected %v", n, newest, expected)
+		}
+	}
+}
+
+func TestVersionOf(t *testing.T) {
+	var tests = map[string]string{
+		"go1.21.0.linux-amd64.tar.gz":     "go1.21.0",
+		"go1.21rc2.windows-amd64.zip":     "go1.21rc2",
+		"go1.9.2rc2.darwin-amd64.pkg":     "go1.9.2rc2",
+		"go1.4-bootstrap-20171003.tar.gz": "go1.4",
+		"go1.src.tar.gz":                  "go1",
+		"go1.0.3.src.tar.gz":              "go1.0.3",
+	}
+
+	for filename, version := range tests {
+		if v := versionOf(filename); v != version {
+			t.Errorf("%s: got %s, expected %s", filename, v, version)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package project
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/paths"
+)
+
+// VersionFile is the file written by Write.
+const VersionFile = ".go-version"
+
+// Pin is a go version requested by a project file.
+type Pin struct {
+	Version string `json:"version"` // version query, like go1.21.5 or 1.21
+	File    string `json:"file"`
+}
+
+// Files are the project files in order of precedence within a directory.
+var Files = []string{VersionFile, ".gvmrc", "go.mod"}
+
+var parser = map[string]func(data []byte) string{
+	VersionFile: parseVersionFile,
+	".gvmrc":    parseVersionFile,
+	"go.mod":    parseGoMod,
+}
+
+// parseVersionFile returns the first line that is not empty or a comment.
+func parseVersionFile(data []byte) string {
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		// a shell rc file, not a version file
+		if strings.HasPrefix(line, "export ") {
+			return ""
+		}
+		return line
+	}
+	return ""
+}
+
+// parseGoMod returns the toolchain directive of a go.mod file, or the go
+// directive as a version query: "go 1.21.3" pins go1.21.3, "go 1.21" the
+// newest patch of go1.21.
+func parseGoMod(data []byte) string {
+	var goVersion, toolchain string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "//"); index >= 0 {
+			line = line[:index]
+		}
+
+		field := strings.Fields(line)
+		if len(field) != 2 {
+			continue
+		}
+
+		switch field[0] {
+		case "go":
+			goVersion = field[1]
+		case "toolchain":
+			if field[1] != "default" {
+				toolchain = field[1]
+			}
+		}
+	}
+
+	if toolchain != "" {
+		return toolchain
+	}
+
+	if strings.Count(goVersion, ".") >= 2 {
+		return "go" + goVersion
+	}
+
+	return goVersion
+}
+
+// Read returns the version pinned by filename.
+func Read(filename string) (pin Pin, err error) {
+	parse, exists := parser[filepath.Base(filename)]
+	if !exists {
+		return pin, fmt.Errorf("unknown project file: %s", filename)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	if pin.Version = parse(data); pin.Version == "" {
+		return pin, fmt.Errorf("no go version in %s", filename)
+	}
+	pin.File = filename
+
+	return
+}
+
+// Find walks up from dir and returns the first project file pinning a
+// version. The global ~/.gvmrc is not a project file.
+func Find(dir string) (pin Pin, ok bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		debug.Println("project: abs error:", err.Error())
+		return
+	}
+
+	for {
+		for _, name := range Files {
+			filename := filepath.Join(dir, name)
+			if filename == paths.GvmRunCom() {
+				continue
+			}
+
+			if pin, err = Read(filename); err == nil {
+				return pin, true
+			}
+			if !os.IsNotExist(err) {
+				debug.Println("project: read error:", err.Error())
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Pin{}, false
+		}
+		dir = parent
+	}
+}
+
+// Write pins version in the .go-version file of dir.
+func Write(dir, version string) (filename string, err error) {
+	filename = filepath.Join(dir, VersionFile)
+	err = ioutil.WriteFile(filename, []byte(version+"\n"), 0644)
+	return
+}
+
+// Upgrade rewrites the pin of version old in filename to version new and
+// reports whether filename pinned old. Only an exact pin is rewritten, in
+// go.mod only the toolchain directive.
+func Upgrade(filename, old, new string) (ok bool, err error) {
+	if _, exists := parser[filepath.Base(filename)]; !exists {
+		return false, fmt.Errorf("unknown project file: %s", filename)
+	}
+
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	var lines = strings.Split(string(data), "\n")
+	for i, line := range lines {
+		var trimmed = strings.TrimSpace(line)
+		if filepath.Base(filename) == "go.mod" {
+			if index := strings.Index(trimmed, "//"); index >= 0 {
+				trimmed = trimmed[:index]
+			}
+			if field := strings.Fields(trimmed); len(field) == 2 && field[0] == "toolchain" && field[1] == old {
+				lines[i], ok = strings.Replace(line, old, new, 1), true
+				break
+			}
+			continue
+		}
+
+		// the first line that is not empty or a comment is the pin
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		if ok = trimmed == old; ok {
+			lines[i] = strings.Replace(line, old, new, 1)
+		}
+		break
+	}
+
+	if !ok {
+		return
+	}
+
+	return true, ioutil.WriteFile(filename, []byte(strings.Join(lines, "\n")), stat.Mode().Perm())
+}
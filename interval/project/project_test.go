@@ -0,0 +1,101 @@
+package project
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func write(t *testing.T, filename, data string) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseGoMod(t *testing.T) {
+	var tests = map[string]string{
+		"module a\n\ngo 1.20\n":                             "1.20",
+		"module a\n\ngo 1.21.3\n":                           "go1.21.3",
+		"module a\n\ngo 1.21 // lang\n\ntoolchain go1.22.1": "go1.22.1",
+		"module a\n\ngo 1.22.0\ntoolchain default\n":        "go1.22.0",
+		"module a\n": "",
+	}
+
+	for data, version := range tests {
+		if v := parseGoMod([]byte(data)); v != version {
+			t.Errorf("%q: got %q, expected %q", data, v, version)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	var root = t.TempDir()
+	write(t, filepath.Join(root, "go.mod"), "module a\n\ngo 1.21\n")
+	write(t, filepath.Join(root, "a", ".go-version"), "# pinned\ngo1.20.14\n")
+	write(t, filepath.Join(root, "a", "b", "go.mod"), "module b\n")
+	write(t, filepath.Join(root, "c", ".gvmrc"), "export GOROOT=/usr/local/go\n")
+
+	var tests = []struct {
+		dir     string
+		version string
+		file    string
+	}{
+		{dir: root, version: "1.21", file: filepath.Join(root, "go.mod")},
+		{dir: filepath.Join(root, "a", "b"), version: "go1.20.14", file: filepath.Join(root, "a", ".go-version")},
+		{dir: filepath.Join(root, "c"), version: "1.21", file: filepath.Join(root, "go.mod")},
+	}
+
+	for _, test := range tests {
+		pin, ok := Find(test.dir)
+		if !ok {
+			t.Errorf("%s: no pin found", test.dir)
+			continue
+		}
+		if pin.Version != test.version || pin.File != test.file {
+			t.Errorf("%s: got %+v", test.dir, pin)
+		}
+	}
+
+	filename, err := Write(filepath.Join(root, "c"), "go1.19.13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin, ok := Find(filepath.Join(root, "c")); !ok || pin.Version != "go1.19.13" || pin.File != filename {
+		t.Errorf("got %+v", pin)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	var root = t.TempDir()
+
+	var tests = []struct {
+		name string
+		data string
+		ok   bool
+		want string
+	}{
+		{".go-version", "# pinned\ngo1.21.3\n", true, "# pinned\ngo1.21.13\n"},
+		{".go-version", "1.21\n", false, "1.21\n"},
+		{".gvmrc", "export GOROOT=/usr/local/go\n", false, "export GOROOT=/usr/local/go\n"},
+		{"go.mod", "module a\n\ngo 1.21.3\n\ntoolchain go1.21.3 // pinned\n", true, "module a\n\ngo 1.21.3\n\ntoolchain go1.21.13 // pinned\n"},
+		{"go.mod", "module a\n\ngo 1.21.3\n", false, "module a\n\ngo 1.21.3\n"},
+	}
+
+	for i, test := range tests {
+		var filename = filepath.Join(root, string(rune('a'+i)), test.name)
+		write(t, filename, test.data)
+
+		ok, err := Upgrade(filename, "go1.21.3", "go1.21.13")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadFile(filename)
+		if ok != test.ok || string(data) != test.want {
+			t.Errorf("%s %q: got %v %q, expected %v %q", test.name, test.data, ok, data, test.ok, test.want)
+		}
+	}
+}
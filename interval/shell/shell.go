@@ -0,0 +1,94 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+var hooks = map[string]string{
+	"bash": `_gvm_hook() {
+  local status=$?
+  if [ "$PWD" != "${_GVM_PWD-}" ]; then
+    _GVM_PWD="$PWD"
+    eval "$(%[1]s env bash)"
+  fi
+  return $status
+}
+if [[ ";${PROMPT_COMMAND:-};" != *";_gvm_hook;"* ]]; then
+  PROMPT_COMMAND="_gvm_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`,
+	"zsh": `_gvm_hook() {
+  eval "$(%[1]s env zsh)"
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd _gvm_hook
+_gvm_hook
+`,
+	"fish": `function _gvm_hook --on-variable PWD
+    %[1]s env fish | source
+end
+_gvm_hook
+`,
+}
+
+// Shells returns the supported shells.
+func Shells() []string {
+	return []string{"bash", "zsh", "fish"}
+}
+
+func check(shell string) error {
+	if _, exists := hooks[shell]; !exists {
+		return fmt.Errorf("unsupported shell: %s, supported: %s", shell, strings.Join(Shells(), ", "))
+	}
+	return nil
+}
+
+// Quote quotes s for a posix shell or fish.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Hook returns the script running "gvm env" on every directory change, gvm
+// is the path of the gvm binary.
+func Hook(shell, gvm string) (string, error) {
+	if err := check(shell); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(hooks[shell], Quote(gvm)), nil
+}
+
+// Export returns the statement setting key to val in the current session.
+// A fish PATH is set as a list of its elements.
+func Export(shell, key, val string) (string, error) {
+	if err := check(shell); err != nil {
+		return "", err
+	}
+
+	if shell != "fish" {
+		return fmt.Sprintf("export %s=%s", key, Quote(val)), nil
+	}
+
+	var values = []string{val}
+	if key == "PATH" {
+		values = strings.Split(val, ":")
+	}
+	for i := range values {
+		values[i] = Quote(values[i])
+	}
+
+	return fmt.Sprintf("set -gx %s %s", key, strings.Join(values, " ")), nil
+}
+
+// Unset returns the statement removing key from the current session.
+func Unset(shell, key string) (string, error) {
+	if err := check(shell); err != nil {
+		return "", err
+	}
+
+	if shell == "fish" {
+		return fmt.Sprintf("set -e %s", key), nil
+	}
+
+	return fmt.Sprintf("unset %s", key), nil
+}
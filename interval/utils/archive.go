@@ -0,0 +1,351 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+)
+
+// extractor writes archive entries below dir. Entries escaping dir, links
+// pointing outside of dir and special files are rejected. Modes and times
+// of directories are applied by finish, after their content is written.
+type extractor struct {
+	dir  string
+	dirs map[string]entry
+}
+
+type entry struct {
+	mode  os.FileMode
+	mtime time.Time
+}
+
+func newExtractor(dir string) (*extractor, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &extractor{dir: dir, dirs: make(map[string]entry)}, nil
+}
+
+// path returns the file name of the archive entry name inside dir.
+func (e *extractor) path(name string) (string, error) {
+	var clean = filepath.Clean(filepath.FromSlash(strings.ReplaceAll(name, `\`, "/")))
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || strings.HasPrefix(clean, string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q: absolute path", name)
+	}
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q: path escapes %s", name, e.dir)
+	}
+
+	// links are checked lexically, so no entry may be written through one
+	var parent = e.dir
+	for _, elem := range strings.Split(filepath.Dir(clean), string(filepath.Separator)) {
+		if elem == "." {
+			break
+		}
+		parent = filepath.Join(parent, elem)
+		if stat, err := os.Lstat(parent); err == nil && stat.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry %q: path through link %s", name, parent)
+		}
+	}
+
+	return filepath.Join(e.dir, clean), nil
+}
+
+// inside reports whether filename is dir or below it.
+func (e *extractor) inside(filename string) bool {
+	rel, err := filepath.Rel(e.dir, filename)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// parent creates the parent dirs of filename and removes a previous entry
+// at filename, so a file is never written through an earlier link.
+func (e *extractor) parent(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	if stat, err := os.Lstat(filename); err == nil && !stat.IsDir() {
+		return os.Remove(filename)
+	}
+	return nil
+}
+
+func (e *extractor) mkdir(name string, mode os.FileMode, mtime time.Time) error {
+	filename, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filename, 0755); err != nil {
+		return err
+	}
+	e.dirs[filename] = entry{mode: mode.Perm(), mtime: mtime}
+	return nil
+}
+
+func (e *extractor) file(name string, mode os.FileMode, mtime time.Time, r io.Reader) (err error) {
+	filename, err := e.path(name)
+	if err != nil {
+		return
+	}
+	if err = e.parent(filename); err != nil {
+		return
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0200)
+	if err != nil {
+		return
+	}
+
+	if _, err = io.Copy(file, r); err != nil {
+		file.Close()
+		return
+	}
+	if err = file.Close(); err != nil {
+		return
+	}
+
+	// the umask applies to OpenFile
+	if err = os.Chmod(filename, mode.Perm()); err != nil {
+		return
+	}
+
+	return chtimes(filename, mtime)
+}
+
+// chtimes sets the times of filename, unless mtime is unknown.
+func chtimes(filename string, mtime time.Time) error {
+	if mtime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(filename, mtime, mtime)
+}
+
+func (e *extractor) symlink(name, target string) (err error) {
+	filename, err := e.path(name)
+	if err != nil {
+		return
+	}
+
+	if err = e.parent(filename); err != nil {
+		return
+	}
+
+	var clean = filepath.FromSlash(target)
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || !e.inside(e.resolve(filepath.Dir(filename), clean)) {
+		return fmt.Errorf("archive entry %q: link target %q escapes %s", name, target, e.dir)
+	}
+
+	return os.Symlink(clean, filename)
+}
+
+// resolve returns the file name of the link target relative to dir. The
+// target is resolved lexically, so ".." may only follow directories: after
+// a link, or an entry not written yet, it resolves to the parent of dir.
+func (e *extractor) resolve(dir, target string) string {
+	var lexical = true
+	for _, elem := range strings.Split(target, string(filepath.Separator)) {
+		switch elem {
+		case "", ".":
+		case "..":
+			if !lexical {
+				return filepath.Dir(e.dir)
+			}
+			dir = filepath.Dir(dir)
+		default:
+			dir = filepath.Join(dir, elem)
+			if stat, err := os.Lstat(dir); err != nil || !stat.IsDir() {
+				lexical = false
+			}
+		}
+	}
+	return dir
+}
+
+func (e *extractor) hardlink(name, target string) (err error) {
+	filename, err := e.path(name)
+	if err != nil {
+		return
+	}
+
+	source, err := e.path(target)
+	if err != nil {
+		return fmt.Errorf("archive entry %q: link target %q escapes %s", name, target, e.dir)
+	}
+
+	if err = e.parent(filename); err != nil {
+		return
+	}
+
+	return os.Link(source, filename)
+}
+
+// finish applies the modes and times of the directories, deepest first.
+func (e *extractor) finish() error {
+	var dirs = make([]string, 0, len(e.dirs))
+	for dir := range e.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+
+	for _, dir := range dirs {
+		var entry = e.dirs[dir]
+		if err := os.Chmod(dir, entry.mode); err != nil {
+			return err
+		}
+		if err := chtimes(dir, entry.mtime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Untar extracts the tar stream r into dir.
+func Untar(r io.Reader, dir string) (err error) {
+	e, err := newExtractor(dir)
+	if err != nil {
+		return
+	}
+
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		var mode = header.FileInfo().Mode()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = e.mkdir(header.Name, mode, header.ModTime)
+		case tar.TypeReg, tar.TypeRegA:
+			err = e.file(header.Name, mode, header.ModTime, reader)
+		case tar.TypeSymlink:
+			err = e.symlink(header.Name, header.Linkname)
+		case tar.TypeLink:
+			err = e.hardlink(header.Name, header.Linkname)
+		case tar.TypeXGlobalHeader:
+		default:
+			err = fmt.Errorf("archive entry %q: unsupported type %q", header.Name, header.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.finish()
+}
+
+// Untargz extracts the tar.gz file filename into dir, showing the progress
+// on bar or a new bar if bar is nil.
+func Untargz(filename, dir string, bar *pb.ProgressBar) (err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return
+	}
+
+	bar, done := progress(bar, stat.Size(), os.Stderr)
+	defer done()
+
+	return UntargzReader(bar.NewProxyReader(file), dir)
+}
+
+// UntargzReader extracts the tar.gz stream r into dir.
+func UntargzReader(r io.Reader, dir string) (err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return
+	}
+	defer gz.Close()
+
+	return Untar(gz, dir)
+}
+
+// Unzip extracts the zip file filename into dir, showing the progress on
+// bar or a new bar if bar is nil.
+func Unzip(filename, dir string, bar *pb.ProgressBar) (err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return
+	}
+
+	bar, done := progress(bar, stat.Size(), os.Stderr)
+	defer done()
+
+	reader, err := zip.NewReader(file, stat.Size())
+	if err != nil {
+		return
+	}
+
+	var total int64
+	for _, f := range reader.File {
+		total += f.FileInfo().Size()
+	}
+	bar.SetTotal(total)
+
+	e, err := newExtractor(dir)
+	if err != nil {
+		return
+	}
+
+	for _, f := range reader.File {
+		if err = unzip(e, f, bar); err != nil {
+			return
+		}
+	}
+
+	return e.finish()
+}
+
+func unzip(e *extractor, f *zip.File, bar *pb.ProgressBar) (err error) {
+	var mode = f.Mode()
+	if mode.IsDir() {
+		return e.mkdir(f.Name, mode, f.Modified)
+	}
+
+	file, err := f.Open()
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	switch {
+	case mode&os.ModeSymlink != 0:
+		var target strings.Builder
+		if _, err = io.Copy(&target, io.LimitReader(file, 4096)); err != nil {
+			return
+		}
+		return e.symlink(f.Name, target.String())
+	case mode.IsRegular():
+		return e.file(f.Name, mode, f.Modified, bar.NewProxyReader(file))
+	}
+
+	return fmt.Errorf("archive entry %q: unsupported mode %s", f.Name, mode)
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var mtime = time.Date(2023, 8, 8, 16, 0, 0, 0, time.UTC)
+
+type item struct {
+	name string
+	typ  byte
+	mode int64
+	body string
+	link string
+}
+
+func writeTargz(t *testing.T, items []item) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, it := range items {
+		header := &tar.Header{
+			Name:     it.name,
+			Typeflag: it.typ,
+			Mode:     it.mode,
+			Size:     int64(len(it.body)),
+			Linkname: it.link,
+			ModTime:  mtime,
+		}
+		if it.typ != tar.TypeReg {
+			header.Size = 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Size > 0 {
+			if _, err := tw.Write([]byte(it.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "go.tar.gz")
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func writeZip(t *testing.T, items []item) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, it := range items {
+		header := &zip.FileHeader{Name: it.name, Method: zip.Deflate, Modified: mtime}
+		var mode = os.FileMode(it.mode)
+		switch it.typ {
+		case tar.TypeDir:
+			mode |= os.ModeDir
+		case tar.TypeSymlink:
+			mode |= os.ModeSymlink
+			it.body = it.link
+		}
+		header.SetMode(mode)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(it.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "go.zip")
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+var goTree = []item{
+	{name: "go/", typ: tar.TypeDir, mode: 0755},
+	{name: "go/bin/", typ: tar.TypeDir, mode: 0755},
+	{name: "go/bin/go", typ: tar.TypeReg, mode: 0755, body: "#!/bin/sh\n"},
+	{name: "go/VERSION", typ: tar.TypeReg, mode: 0644, body: "go1.21.0\n"},
+	{name: "go/misc/readonly", typ: tar.TypeReg, mode: 0444, body: "ro"},
+	{name: "go/lib/time/zoneinfo.zip", typ: tar.TypeReg, mode: 0644, body: "zip"},
+	{name: "go/lib/link", typ: tar.TypeSymlink, link: "time/zoneinfo.zip"},
+	{name: "go/lib/up", typ: tar.TypeSymlink, link: "../VERSION"},
+}
+
+func checkTree(t *testing.T, dir string, hardlink bool) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "go", "VERSION"))
+	if err != nil || string(data) != "go1.21.0\n" {
+		t.Fatalf("VERSION: %q, %v", data, err)
+	}
+
+	stat, err := os.Stat(filepath.Join(dir, "go", "bin", "go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && stat.Mode().Perm() != 0755 {
+		t.Errorf("bin/go mode: %s", stat.Mode())
+	}
+	if !stat.ModTime().Equal(mtime) {
+		t.Errorf("bin/go mtime: %s", stat.ModTime())
+	}
+
+	if stat, err = os.Stat(filepath.Join(dir, "go", "misc", "readonly")); err != nil || (runtime.GOOS != "windows" && stat.Mode().Perm() != 0444) {
+		t.Errorf("readonly: %v, %v", stat.Mode(), err)
+	}
+
+	if stat, err = os.Stat(filepath.Join(dir, "go", "bin")); err != nil || !stat.ModTime().Equal(mtime) {
+		t.Errorf("bin mtime: %v, %v", stat.ModTime(), err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	for link, content := range map[string]string{"link": "zip", "up": "go1.21.0\n"} {
+		data, err = ioutil.ReadFile(filepath.Join(dir, "go", "lib", link))
+		if err != nil || string(data) != content {
+			t.Errorf("%s: %q, %v", link, data, err)
+		}
+	}
+
+	if hardlink {
+		data, err = ioutil.ReadFile(filepath.Join(dir, "go", "hard"))
+		if err != nil || string(data) != "go1.21.0\n" {
+			t.Errorf("hard: %q, %v", data, err)
+		}
+	}
+}
+
+func TestUntargz(t *testing.T) {
+	var items = append(goTree, item{name: "go/hard", typ: tar.TypeLink, link: "go/VERSION"})
+	var dir = t.TempDir()
+	if err := Untargz(writeTargz(t, items), dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, dir, true)
+}
+
+func TestUnzip(t *testing.T) {
+	var dir = t.TempDir()
+	if err := Unzip(writeZip(t, goTree), dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, dir, false)
+}
+
+func TestExtractEscape(t *testing.T) {
+	var tests = map[string][]item{
+		"parent":        {{name: "../evil", typ: tar.TypeReg, mode: 0644, body: "x"}},
+		"nested parent": {{name: "go/../../evil", typ: tar.TypeReg, mode: 0644, body: "x"}},
+		"absolute":      {{name: "/tmp/evil", typ: tar.TypeReg, mode: 0644, body: "x"}},
+		"symlink":       {{name: "go/link", typ: tar.TypeSymlink, link: "../../evil"}},
+		"abs symlink":   {{name: "go/link", typ: tar.TypeSymlink, link: "/etc/passwd"}},
+		"hardlink":      {{name: "go/hard", typ: tar.TypeLink, link: "../evil"}},
+		"through link": {
+			{name: "go/", typ: tar.TypeDir, mode: 0755},
+			{name: "go/up", typ: tar.TypeSymlink, link: ".."},
+			{name: "go/up/link", typ: tar.TypeSymlink, link: "../evil"},
+		},
+		"link chain": {
+			{name: "go/", typ: tar.TypeDir, mode: 0755},
+			{name: "go/b", typ: tar.TypeSymlink, link: "."},
+			{name: "go/a", typ: tar.TypeSymlink, link: "b/b/../../evil"},
+		},
+		"link chain reversed": {
+			{name: "go/", typ: tar.TypeDir, mode: 0755},
+			{name: "go/a", typ: tar.TypeSymlink, link: "b/b/../../evil"},
+			{name: "go/b", typ: tar.TypeSymlink, link: "."},
+		},
+	}
+
+	for name, items := range tests {
+		var root = t.TempDir()
+		var dir = filepath.Join(root, "home")
+
+		err := Untargz(writeTargz(t, items), dir, nil)
+		if err == nil || !strings.Contains(err.Error(), "archive entry") {
+			t.Errorf("tar %s: expected error, got %v", name, err)
+		}
+
+		if name == "hardlink" {
+			continue
+		}
+
+		err = Unzip(writeZip(t, items), dir, nil)
+		if err == nil || !strings.Contains(err.Error(), "archive entry") {
+			t.Errorf("zip %s: expected error, got %v", name, err)
+		}
+
+		if _, err = os.Lstat(filepath.Join(root, "evil")); !os.IsNotExist(err) {
+			t.Errorf("%s: file written outside of dir", name)
+		}
+	}
+}
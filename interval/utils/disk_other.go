@@ -0,0 +1,13 @@
+//+build !linux,!darwin,!freebsd,!windows
+
+package utils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// DiskFree returns the bytes available to the user on the file system of path.
+func DiskFree(path string) (free uint64, err error) {
+	return 0, fmt.Errorf("disk free space of %s: not supported on %s", path, runtime.GOOS)
+}
@@ -0,0 +1,14 @@
+//+build linux darwin freebsd
+
+package utils
+
+import "syscall"
+
+// DiskFree returns the bytes available to the user on the file system of path.
+func DiskFree(path string) (free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(path, &stat); err != nil {
+		return
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
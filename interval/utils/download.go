@@ -0,0 +1,548 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+
+	"github.com/zooyer/gvm/interval/conf"
+	"github.com/zooyer/gvm/interval/debug"
+)
+
+// ChecksumError reports a downloaded file whose sha256 does not match.
+type ChecksumError struct {
+	Filename string
+	Expected string
+	Actual   string
+
+	resumed bool
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s: sha256 mismatch, expected %s, actual %s", filepath.Base(e.Filename), e.Expected, e.Actual)
+}
+
+// StatusError reports an unexpected http response status.
+type StatusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.URL, e.Status)
+}
+
+// partial describes a partially downloaded file, it is stored next to the
+// file and used to validate that the remote content did not change before
+// resuming.
+type partial struct {
+	URL    string `json:"url"`
+	ETag   string `json:"etag"`
+	Length int64  `json:"length"`
+}
+
+// progress returns bar with total set, or a bar started on w if bar is nil,
+// and the func finishing a started bar. A given bar is owned by the
+// caller, like a bar of a pool showing several downloads.
+func progress(bar *pb.ProgressBar, total int64, w io.Writer) (*pb.ProgressBar, func()) {
+	if bar == nil {
+		bar = pb.New64(total).SetTemplate(pb.Default).SetWriter(w).Start()
+		return bar, func() { bar.Finish() }
+	}
+
+	bar.SetTotal(total)
+	bar.SetCurrent(0)
+	return bar, func() {}
+}
+
+// Downloader downloads files, retrying failed attempts with exponential
+// backoff.
+type Downloader struct {
+	// Retry is the number of times a failed attempt is retried.
+	Retry int
+
+	// Output receives the progress and the retries if no bar is given,
+	// default stderr.
+	Output io.Writer
+}
+
+func (d Downloader) output() io.Writer {
+	if d.Output == nil {
+		return os.Stderr
+	}
+	return d.Output
+}
+
+// Download fetches url into filename, showing the progress on bar or a new
+// bar if bar is nil. If checksum is not empty, the sha256 of the content is
+// computed while streaming and the file is removed when it does not match.
+//
+// An existing partial file is resumed with an http range request, failed
+// attempts are retried d.Retry times. A download canceled with ctx is
+// resumed by the next one.
+func (d Downloader) Download(ctx context.Context, url, filename, checksum string, bar *pb.ProgressBar) (err error) {
+	return d.attempt(ctx, bar, func() error {
+		return download(ctx, url, filename, checksum, bar, d.output())
+	})
+}
+
+// Stream fetches url and passes the content to fn while it is downloaded,
+// so the archive is not stored. The sha256 of the content is verified
+// after fn returned, the caller must discard what fn produced if Stream
+// fails. If filename is not empty, the verified content is also saved to
+// filename.
+//
+// A failed attempt is retried like Download, calling fn again from the
+// start of the content. Stream is canceled with ctx.
+func (d Downloader) Stream(ctx context.Context, url, checksum, filename string, bar *pb.ProgressBar, fn func(r io.Reader) error) (err error) {
+	return d.attempt(ctx, bar, func() error {
+		return stream(ctx, url, checksum, filename, bar, d.output(), fn)
+	})
+}
+
+// attempt runs fn, retrying it d.Retry times with exponential backoff
+// until ctx is done.
+func (d Downloader) attempt(ctx context.Context, bar *pb.ProgressBar, fn func() error) (err error) {
+	var delay = time.Second
+	for retry := 0; ; retry++ {
+		if err = fn(); err == nil || retry >= d.Retry || !retryable(err) || ctx.Err() != nil {
+			if bar != nil {
+				bar.Set("suffix", nil)
+			}
+			return
+		}
+
+		if bar != nil {
+			bar.Set("suffix", fmt.Sprintf("retry in %s", delay))
+		} else {
+			fmt.Fprintf(d.output(), "download error: %v, retry in %s\n", err, delay)
+		}
+		select {
+		case <-ctx.Done():
+			if bar != nil {
+				bar.Set("suffix", nil)
+			}
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func retryable(err error) bool {
+	var checksum *ChecksumError
+	if errors.As(err, &checksum) {
+		// a resumed download may have been combined with stale content,
+		// the file is removed so the next attempt starts over.
+		return checksum.resumed
+	}
+
+	var status *StatusError
+	if errors.As(err, &status) {
+		switch status.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return status.StatusCode >= 500
+	}
+
+	var path *os.PathError
+	return !errors.As(err, &path)
+}
+
+var (
+	clientMu      sync.Mutex
+	clientTimeout time.Duration
+	client        *http.Client
+)
+
+// httpClient returns the client of downloads. Connecting and waiting for
+// the response are limited by timeout, a download as a whole is not.
+func httpClient(timeout time.Duration) *http.Client {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if client == nil || clientTimeout != timeout {
+		clientTimeout = timeout
+		client = &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext,
+				TLSHandshakeTimeout:   timeout,
+				ResponseHeaderTimeout: timeout,
+				IdleConnTimeout:       90 * time.Second,
+			},
+		}
+	}
+
+	return client
+}
+
+// request sends a GET of url, a range request from offset if it is not 0.
+// Reading the body fails when no data arrives for conf.Timeout.
+func request(ctx context.Context, url string, offset int64, etag string) (res *http.Response, err error) {
+	var timeout = conf.Timeout.Duration()
+	ctx, cancel := context.WithCancel(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if etag != "" {
+			req.Header.Set("If-Range", etag)
+		}
+	}
+
+	if res, err = httpClient(timeout).Do(req); err != nil {
+		cancel()
+		return
+	}
+
+	if timeout > 0 {
+		res.Body = newIdleBody(res.Body, url, timeout, cancel)
+	} else {
+		res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	}
+
+	return
+}
+
+// cancelBody cancels the request of the body when it is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// idleBody cancels the request of the body when no data is read for
+// timeout, a stalled connection would block a read forever otherwise.
+type idleBody struct {
+	cancelBody
+	url     string
+	timeout time.Duration
+	timer   *time.Timer
+	idle    int32
+}
+
+func newIdleBody(body io.ReadCloser, url string, timeout time.Duration, cancel context.CancelFunc) *idleBody {
+	var b = &idleBody{cancelBody: cancelBody{ReadCloser: body, cancel: cancel}, url: url, timeout: timeout}
+	b.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&b.idle, 1)
+		cancel()
+	})
+	return b
+}
+
+func (b *idleBody) Read(p []byte) (n int, err error) {
+	if n, err = b.ReadCloser.Read(p); err != nil && atomic.LoadInt32(&b.idle) == 1 {
+		return n, fmt.Errorf("%s: no data received for %s", b.url, b.timeout)
+	}
+	b.timer.Reset(b.timeout)
+	return
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	return b.cancelBody.Close()
+}
+
+// contentRange parses "bytes start-end/total".
+func contentRange(value string) (start, total int64, ok bool) {
+	if !strings.HasPrefix(value, "bytes ") {
+		return
+	}
+
+	field := strings.SplitN(strings.TrimPrefix(value, "bytes "), "/", 2)
+	if len(field) != 2 {
+		return
+	}
+
+	var err error
+	if index := strings.Index(field[0], "-"); index > 0 {
+		if start, err = strconv.ParseInt(field[0][:index], 10, 64); err != nil {
+			return
+		}
+	}
+
+	if total, err = strconv.ParseInt(field[1], 10, 64); err != nil {
+		return
+	}
+
+	return start, total, true
+}
+
+func readPartial(filename string) (p partial, err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &p)
+	return
+}
+
+func writePartial(filename string, p partial) (err error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+func hashFile(h hash.Hash, filename string) (err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	_, err = io.Copy(h, file)
+	return
+}
+
+func verify(h hash.Hash, filename, checksum string, resumed bool) error {
+	if checksum == "" {
+		return nil
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, checksum) {
+		return &ChecksumError{
+			Filename: filename,
+			Expected: strings.ToLower(checksum),
+			Actual:   sum,
+			resumed:  resumed,
+		}
+	}
+
+	return nil
+}
+
+// VerifyFile checks that the sha256 of filename is checksum.
+func VerifyFile(filename, checksum string) (err error) {
+	var h = sha256.New()
+	if err = hashFile(h, filename); err != nil {
+		return
+	}
+	return verify(h, filename, checksum, false)
+}
+
+func download(ctx context.Context, url, filename, checksum string, bar *pb.ProgressBar, out io.Writer) (err error) {
+	var meta = filename + ".partial"
+	var offset int64
+
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return
+	}
+
+	state, err := readPartial(meta)
+	if stat, e := os.Stat(filename); e == nil {
+		switch {
+		case err == nil && state.URL == url:
+			offset = stat.Size()
+		case os.IsNotExist(err) && checksum != "":
+			// completed by a previous run
+			var h = sha256.New()
+			if hashFile(h, filename) == nil && verify(h, filename, checksum, false) == nil {
+				return nil
+			}
+		}
+	}
+
+	res, err := request(ctx, url, offset, state.ETag)
+	if err != nil {
+		return
+	}
+	defer func() { res.Body.Close() }()
+
+	if offset > 0 {
+		var resumed bool
+		switch res.StatusCode {
+		case http.StatusPartialContent:
+			start, total, ok := contentRange(res.Header.Get("Content-Range"))
+			etag := res.Header.Get("ETag")
+			resumed = ok && start == offset && total == state.Length && (state.ETag == "" || etag == "" || etag == state.ETag)
+		case http.StatusRequestedRangeNotSatisfiable:
+			if offset == state.Length {
+				var h = sha256.New()
+				if err = hashFile(h, filename); err != nil {
+					return
+				}
+				if err = verify(h, filename, checksum, true); err != nil {
+					os.Remove(filename)
+					os.Remove(meta)
+					return
+				}
+				return os.Remove(meta)
+			}
+		}
+
+		if !resumed {
+			debug.Println("utils: discard stale partial download:", filename)
+			offset = 0
+			if res.StatusCode != http.StatusOK {
+				res.Body.Close()
+				if res, err = request(ctx, url, 0, ""); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	switch {
+	case offset > 0 && res.StatusCode == http.StatusPartialContent:
+	case res.StatusCode == http.StatusOK:
+		state = partial{
+			URL:    url,
+			Length: res.ContentLength,
+		}
+		if etag := res.Header.Get("ETag"); !strings.HasPrefix(etag, "W/") {
+			state.ETag = etag
+		}
+		if state.Length < 0 {
+			// without a length the partial can not be validated
+			os.Remove(meta)
+		} else if err = writePartial(meta, state); err != nil {
+			return
+		}
+	default:
+		return &StatusError{URL: url, Status: res.Status, StatusCode: res.StatusCode}
+	}
+
+	var h = sha256.New()
+	var file *os.File
+	if offset > 0 {
+		if err = hashFile(h, filename); err != nil {
+			return
+		}
+		file, err = os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0644)
+	} else {
+		file, err = os.Create(filename)
+	}
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	bar, done := progress(bar, state.Length, out)
+	bar.SetCurrent(offset)
+	defer done()
+
+	n, err := io.Copy(bar.NewProxyWriter(io.MultiWriter(file, h)), res.Body)
+	if err != nil {
+		return
+	}
+
+	if state.Length >= 0 && offset+n != state.Length {
+		return io.ErrUnexpectedEOF
+	}
+
+	if err = verify(h, filename, checksum, offset > 0); err != nil {
+		file.Close()
+		os.Remove(filename)
+		os.Remove(meta)
+		return
+	}
+
+	if err = os.Remove(meta); os.IsNotExist(err) {
+		err = nil
+	}
+
+	return
+}
+
+func stream(ctx context.Context, url, checksum, filename string, bar *pb.ProgressBar, out io.Writer, fn func(r io.Reader) error) (err error) {
+	res, err := request(ctx, url, 0, "")
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{URL: url, Status: res.Status, StatusCode: res.StatusCode}
+	}
+
+	var h = sha256.New()
+	var w io.Writer = h
+	var file *os.File
+	if filename != "" {
+		if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return
+		}
+		if file, err = ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*"); err != nil {
+			return
+		}
+		defer func() {
+			file.Close()
+			os.Remove(file.Name())
+		}()
+		w = io.MultiWriter(h, file)
+	}
+
+	bar, done := progress(bar, res.ContentLength, out)
+	defer done()
+
+	var counter = &count{r: bar.NewProxyReader(res.Body)}
+	var r = io.TeeReader(counter, w)
+	if err = fn(r); err != nil {
+		return
+	}
+
+	// the decoder may stop before the end, like at the padding of a tar
+	if _, err = io.Copy(ioutil.Discard, r); err != nil {
+		return
+	}
+
+	if res.ContentLength >= 0 && counter.n != res.ContentLength {
+		return io.ErrUnexpectedEOF
+	}
+
+	if err = verify(h, url, checksum, false); err != nil {
+		return
+	}
+
+	if file == nil {
+		return
+	}
+	if err = file.Close(); err != nil {
+		return
+	}
+
+	return os.Rename(file.Name(), filename)
+}
+
+// count counts the bytes read from r.
+type count struct {
+	r io.Reader
+	n int64
+}
+
+func (c *count) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n += int64(n)
+	return
+}
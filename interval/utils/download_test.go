@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zooyer/gvm/interval/conf"
+)
+
+func TestDownloadResume(t *testing.T) {
+	var d = Downloader{Retry: 3}
+	var content = bytes.Repeat([]byte("gvm"), 1<<12)
+	var sum = sha256.Sum256(content)
+	var checksum = hex.EncodeToString(sum[:])
+
+	var etag = `"v1"`
+	var ranges int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges++
+		}
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "go.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	var dir = t.TempDir()
+	var filename = filepath.Join(dir, "go.tar.gz")
+
+	write := func(n int, tag string) {
+		if err := ioutil.WriteFile(filename, content[:n], 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := writePartial(filename+".partial", partial{URL: server.URL, ETag: tag, Length: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func() {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, content) {
+			t.Fatalf("content mismatch: got %d bytes", len(data))
+		}
+		if _, err = os.Stat(filename + ".partial"); !os.IsNotExist(err) {
+			t.Fatal("partial state not removed")
+		}
+	}
+
+	// resume
+	write(1000, etag)
+	if err := d.Download(context.Background(), server.URL, filename, checksum, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ranges != 1 {
+		t.Fatalf("expected a range request, got %d", ranges)
+	}
+	check()
+
+	// stale partial
+	write(1000, `"v0"`)
+	copy(content[:10], "0123456789")
+	sum = sha256.Sum256(content)
+	if err := d.Download(context.Background(), server.URL, filename, hex.EncodeToString(sum[:]), nil); err != nil {
+		t.Fatal(err)
+	}
+	check()
+
+	// checksum mismatch
+	os.Remove(filename)
+	err := d.Download(context.Background(), server.URL, filename, checksum, nil)
+	if _, ok := err.(*ChecksumError); !ok {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal("mismatched file not removed")
+	}
+}
+
+func TestStream(t *testing.T) {
+	var d = Downloader{Retry: 3}
+	var content = bytes.Repeat([]byte("gvm"), 1<<12)
+	var sum = sha256.Sum256(content)
+	var checksum = hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "go.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	var filename = filepath.Join(t.TempDir(), "cache", "go.tar.gz")
+
+	// the reader is drained after fn, the kept archive is complete
+	var head = make([]byte, 100)
+	err := d.Stream(context.Background(), server.URL, checksum, filename, nil, func(r io.Reader) error {
+		_, err := io.ReadFull(r, head)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("content mismatch: got %d bytes", len(data))
+	}
+
+	// checksum mismatch
+	os.Remove(filename)
+	err = d.Stream(context.Background(), server.URL, checksum[1:]+"0", filename, nil, func(r io.Reader) error { return nil })
+	if _, ok := err.(*ChecksumError); !ok {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal("mismatched archive kept")
+	}
+}
+
+func TestDownloadStalled(t *testing.T) {
+	var content = bytes.Repeat([]byte("gvm"), 1<<12)
+	var stalled = make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content[:1000])
+		w.(http.Flusher).Flush()
+		<-stalled
+	}))
+	defer server.Close()
+	defer close(stalled)
+
+	defer func(timeout conf.Duration) {
+		conf.Timeout = timeout
+	}(conf.Timeout)
+	conf.Timeout = conf.Duration(100 * time.Millisecond)
+
+	var filename = filepath.Join(t.TempDir(), "go.tar.gz")
+	var done = make(chan error, 1)
+	go func() { done <- Downloader{}.Download(context.Background(), server.URL, filename, "", nil) }()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "no data received") {
+			t.Fatalf("expected an idle error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled download did not fail")
+	}
+
+	// the received part is resumed by the next attempt
+	if stat, err := os.Stat(filename); err != nil || stat.Size() != 1000 {
+		t.Fatalf("partial download: %v", err)
+	}
+}
@@ -1,167 +1,14 @@
 package utils
 
 import (
-	"archive/tar"
-	"archive/zip"
-	"compress/gzip"
-	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
 	"strings"
-
-	"github.com/cheggaaa/pb/v3"
 )
 
-func Download(url, filename string) (err error) {
-	res, err := http.Get(url)
-	if err != nil {
-		return
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		return errors.New(res.Status)
-	}
-
-	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
-		return
-	}
-
-	file, err := os.Create(filename)
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	bar := pb.Default.Start64(res.ContentLength)
-	defer bar.Finish()
-
-	writer := bar.NewProxyWriter(file)
-	if _, err = io.Copy(writer, res.Body); err != nil {
-		return
-	}
-
-	return
-}
-
-func Untargz(filename, dir string) (err error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	stat, err := file.Stat()
-	if err != nil {
-		return
-	}
-
-	bar := pb.Default.Start64(stat.Size())
-	defer bar.Finish()
-
-	gz, err := gzip.NewReader(bar.NewProxyReader(file))
-	if err != nil {
-		return
-	}
-	defer gz.Close()
-
-	reader := tar.NewReader(gz)
-	for {
-		header, err := reader.Next()
-		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return err
-			}
-		}
-
-		if header.Typeflag != tar.TypeDir {
-			filename := filepath.Join(dir, header.Name)
-			if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
-				return err
-			}
-
-			file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, header.FileInfo().Mode())
-			if err != nil {
-				return err
-			}
-
-			if _, err = io.Copy(file, reader); err != nil {
-				file.Close()
-				return err
-			}
-
-			file.Close()
-		}
-	}
-
-	return nil
-}
-
-func Unzip(filename, dir string) (err error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	stat, err := file.Stat()
-	if err != nil {
-		return
-	}
-
-	bar := pb.Default.Start64(stat.Size())
-	defer bar.Finish()
-
-	reader, err := zip.NewReader(file, stat.Size())
-	if err != nil {
-		return
-	}
-
-	var total int64
-	for _, f := range reader.File {
-		total += f.FileInfo().Size()
-	}
-	bar.SetTotal(total)
-
-	for _, f := range reader.File {
-		file, err := f.Open()
-		if err != nil {
-			return err
-		}
-
-		if !f.FileInfo().IsDir() {
-			filename := filepath.Join(dir, f.Name)
-			if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
-				return err
-			}
-
-			writer, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, f.Mode())
-			if err != nil {
-				return err
-			}
-
-			if _, err = io.Copy(bar.NewProxyWriter(writer), file); err != nil {
-				writer.Close()
-				file.Close()
-				return err
-			}
-
-			writer.Close()
-		}
-
-		file.Close()
-	}
-
-	return
-}
-
 func Command(name string, args ...string) (out string, err error) {
 	cmd := exec.Command(name, args...)
 
@@ -173,6 +20,18 @@ func Command(name string, args ...string) (out string, err error) {
 	return string(output), nil
 }
 
+// RunIn runs the command name in dir with the environment env, writing its
+// output to w.
+func RunIn(dir string, env []string, w io.Writer, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	return cmd.Run()
+}
+
 func Run(name string, args ...string) {
 	out, err := Command(name, args...)
 	if err != nil {
@@ -188,6 +47,17 @@ func Goenv(key string) string {
 	return os.Getenv(key)
 }
 
+// Exec replaces the current process with the command name, with the
+// environment env. Where the process can not be replaced, the command is
+// run and its exit code becomes the exit code of the current process.
+func Exec(name string, args []string, env []string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return err
+	}
+	return execute(path, args, env)
+}
+
 func GetAbsEnv(key string) (val string, err error) {
 	return getAbsEnv(key)
 }
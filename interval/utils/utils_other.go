@@ -9,6 +9,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/zooyer/gvm/interval/paths"
 )
@@ -109,3 +110,7 @@ func getAbsEnv(key string) (val string, err error) {
 func setAbsEnv(key, val string) (err error) {
 	return SetGvmEnv(key, val)
 }
+
+func execute(path string, args []string, env []string) error {
+	return syscall.Exec(path, append([]string{path}, args...), env)
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
 	"syscall"
 	"unsafe"
 
@@ -47,3 +51,27 @@ func setAbsEnv(key, val string) (err error) {
 
 	return
 }
+
+func execute(path string, args []string, env []string) error {
+	cmd := exec.Command(path, args...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// the console delivers ctrl-c to the child as well, it decides when to exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.ExitCode())
+		}
+		return err
+	}
+
+	os.Exit(0)
+	return nil
+}
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"github.com/mattn/go-isatty"
+	"github.com/zooyer/gvm/gvm"
+	"github.com/zooyer/gvm/interval/conf"
 	"github.com/zooyer/gvm/interval/debug"
+	"github.com/zooyer/gvm/interval/files"
+	"github.com/zooyer/gvm/interval/format"
 	"github.com/zooyer/gvm/interval/golang"
 	"github.com/zooyer/gvm/interval/paths"
+	"github.com/zooyer/gvm/interval/project"
+	"github.com/zooyer/gvm/interval/shell"
 	"github.com/zooyer/gvm/interval/utils"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -23,9 +32,41 @@ Commands:
 	use       - use go version
 	info      - show the go info
 	list      - list all go versions
+	local     - pin the go version of the current directory
+	current   - show the active go version and what selected it
+	hook      - print the shell hook switching go on directory change
+	exec      - run a command with a go version, alias run
+	rehash    - regenerate the shims of the go tools
+	cache     - list, prune or clean the archive cache
+	update    - rebuild tip or a git build at its newest commit
+	adopt     - use go installations outside of gvm in place
+	doctor    - diagnose the gvm setup, --fix repairs what it can
+	prune     - uninstall old versions, keeping the recent, pinned and used ones
+	outdated  - list the installed versions with a newer patch release
+	upgrade   - install the newest patch release of a version and switch to it
 	help      - show the help manual
 	install   - install go versions
-	uninstall - uninstall go versions`
+	uninstall - uninstall go versions
+
+Options:
+	--mirror       - download mirrors, comma separated in fallback order
+	--jobs         - number of versions installed concurrently, default 3
+	--stream       - unpack archives while downloading, without storing them
+	--keep-archive - keep streamed archives in the archive cache
+	--offline      - use only the cached version index and installed versions
+	--refresh      - fetch the version index ignoring the cache
+	--format       - output of list, info, current, cache list, install,
+	                 uninstall, prune, outdated and doctor: json, or a go
+	                 template like '{{.Version}}'`
+
+var versionHelp = `
+Versions:
+	go1.9.2         - exact version
+	latest          - newest version, including pre-releases
+	stable          - newest stable version
+	1.21, go1.20.x  - newest patch of a minor version
+	~1.20, ^1.20    - newest patch of the minor, newest minor of the major
+	">=1.19 <1.22"  - newest version matching all comparisons`
 
 var usage = func(command string) string {
 	return helps
@@ -33,30 +74,123 @@ var usage = func(command string) string {
 
 var usages = map[string]func() string{
 	"set": func() string {
-		return fmt.Sprintf("show: %s set go1.9.2", os.Args[0])
+		return fmt.Sprintf("show: %s set go1.9.2\n%s", os.Args[0], versionHelp)
 	},
 	"use": func() string {
-		return fmt.Sprintf("show: %s use go1.9.2", os.Args[0])
+		return fmt.Sprintf("show: %s use go1.9.2\n%s", os.Args[0], versionHelp)
 	},
 	"info": func() string {
 		return fmt.Sprintf("show: %s info", os.Args[0])
 	},
+	"local": func() string {
+		return fmt.Sprintf("show: %s local go1.9.2\n\nwrites %s, set/use/install without a version use the\nversion pinned by .go-version, .gvmrc or go.mod of the nearest directory\n%s", os.Args[0], project.VersionFile, versionHelp)
+	},
+	"current": func() string {
+		return fmt.Sprintf("show: %s current", os.Args[0])
+	},
+	"hook": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s hook bash|zsh|fish\n\n", os.Args[0]))
+		buf.WriteString(fmt.Sprintf("bash: add 'eval \"$(%s hook bash)\"' to ~/.bashrc\n", os.Args[0]))
+		buf.WriteString(fmt.Sprintf("zsh:  add 'eval \"$(%s hook zsh)\"' to ~/.zshrc\n", os.Args[0]))
+		buf.WriteString(fmt.Sprintf("fish: add '%s hook fish | source' to ~/.config/fish/config.fish", os.Args[0]))
+		return buf.String()
+	},
+	"exec": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s exec go1.9.2 [--gotoolchain local] -- go test ./...\n\n", os.Args[0]))
+		buf.WriteString("runs the command with GOROOT and PATH of the version, installing it if\n")
+		buf.WriteString("missing. GOTOOLCHAIN is kept from the environment, --gotoolchain local stops\n")
+		buf.WriteString("go1.21+ from switching to another toolchain.")
+		return buf.String()
+	},
+	"rehash": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s rehash\n\n", os.Args[0]))
+		buf.WriteString("writes a launcher for every tool in the bin dir of the installed versions\n")
+		buf.WriteString("to GOHOME/shims, a launcher runs the tool of the version selected by\n")
+		buf.WriteString("GVM_VERSION, the nearest project file or set. Put the shims first in PATH.")
+		return buf.String()
+	},
+	"cache": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s cache list|prune|clean [--max-size 2G]\n\n", os.Args[0]))
+		buf.WriteString("downloaded archives are kept by sha256 in GVM_CACHE_DIR, default\n")
+		buf.WriteString("GOHOME/cache, and reused by install. prune removes the least recently\n")
+		buf.WriteString("used archives above --max-size, default GVM_CACHE_SIZE, which also\n")
+		buf.WriteString("limits the cache after every install.")
+		return buf.String()
+	},
+	"env": func() string {
+		return fmt.Sprintf("show: %s env bash|zsh|fish", os.Args[0])
+	},
 	"install": func() string {
-		return fmt.Sprintf("show: %s install go1.9.2", os.Args[0])
+		return fmt.Sprintf("show: %s install go1.9.2 [go1.21.5 ...] [--jobs 3] [--stream [--keep-archive]] [--mirror https://golang.google.cn/dl/]\n      %s install --from go1.21.5.linux-amd64.tar.gz|/opt/go [--sha256 <checksum>]\n      %s install --source [go1.21.5|/path/to/checkout ...] [--bootstrap go1.20.6]\n      %s install tip|git:<url>@<ref>\n\n--source builds the versions or checkouts given as arguments, default the\npinned version, with make.bash, bootstrapped by the oldest installed version\nable to build it, logs are written to GOHOME/logs. tip builds GVM_GIT_REMOTE,\nbuilds are named by commit like gotip-0123456789ab. A checkout without a\nVERSION file builds its committed HEAD, named like gogit-0123456789ab.\n%s", os.Args[0], os.Args[0], os.Args[0], os.Args[0], versionHelp)
+	},
+	"update": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s update [tip|git:<url>@<ref>|gogit-<commit>]\n\n", os.Args[0]))
+		buf.WriteString("fetches the ref and builds it if the commit changed, previous builds\n")
+		buf.WriteString("are kept until uninstalled.")
+		return buf.String()
+	},
+	"adopt": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s adopt [/usr/local/go ...]\n\n", os.Args[0]))
+		buf.WriteString("registers go installations by the version of their bin/go, without\n")
+		buf.WriteString("copying them. Without a path, /usr/local/go, /usr/lib/go-*, ~/sdk/go*\n")
+		buf.WriteString("and GOROOT are scanned. uninstall only unregisters an adopted version.")
+		return buf.String()
+	},
+	"doctor": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s doctor [--fix] [--format json]\n\n", os.Args[0]))
+		buf.WriteString("checks GOHOME, leftovers of interrupted installs, the shell rc file,\n")
+		buf.WriteString("PATH, GOROOT and GOTOOLCHAIN and the installed versions, and prints\n")
+		buf.WriteString("a fix for every problem. --fix applies the fixes gvm can make.\n")
+		buf.WriteString("--format writes the problems, with problem, fix, fixable, fixed and error.")
+		return buf.String()
+	},
+	"prune": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s prune [--dry-run] [--keep-patches 2] [--keep-used 30d]\n\n", os.Args[0]))
+		buf.WriteString("uninstalls the versions in GOHOME except the newest --keep-patches patch\n")
+		buf.WriteString("releases of every minor, default GVM_KEEP_PATCHES, the active version,\n")
+		buf.WriteString("the versions pinned by projects gvm has seen and the versions selected by\n")
+		buf.WriteString("set, use or exec within --keep-used, default GVM_KEEP_USED. Adopted\n")
+		buf.WriteString("versions and development builds are kept. --dry-run only lists them.")
+		return buf.String()
+	},
+	"outdated": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s outdated [--refresh] [--format json]\n\n", os.Args[0]))
+		buf.WriteString("compares the installed versions with the version index and lists the\n")
+		buf.WriteString("newest patch release of the same minor version.")
+		return buf.String()
+	},
+	"upgrade": func() string {
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s upgrade [go1.21.3] [--pins] [--remove]\n\n", os.Args[0]))
+		buf.WriteString("installs the newest patch release of the version, default the active\n")
+		buf.WriteString("one, and makes it the global version if the old one was. --pins rewrites\n")
+		buf.WriteString("the exact pins of the old version in the projects gvm has seen, --remove\n")
+		buf.WriteString("uninstalls the old version, which is asked for on a terminal.")
+		return buf.String()
 	},
 	"uninstall": func() string {
 		return fmt.Sprintf("show: %s uninstall go1.9.2", os.Args[0])
 	},
 	"list": func() string {
 		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("show: %s list [--refresh] [--offline] [--format json|'{{.Version}}']\n", os.Args[0]))
 		buf.WriteString("> \033[1;32mcurrented\033[0m\n")
 		buf.WriteString("+ \033[1;36minstalled\033[0m\n")
 		buf.WriteString("- \033[1;37muninstalled\033[0m")
 		return buf.String()
 	},
 	"help": func() string {
-		if len(os.Args) > 1 {
-			return usage(os.Args[2])
+		if len(arguments) > 0 {
+			return usage(arguments[0])
 		}
 		return helps
 	},
@@ -64,6 +198,36 @@ var usages = map[string]func() string{
 
 var command string
 
+// flags are the supported options, true if the option takes a value.
+var flags = map[string]bool{
+	"mirror":  true,
+	"jobs":    true,
+	"offline": false,
+	"refresh": false,
+	"stream":  false,
+
+	"keep-archive": false,
+	"max-size":     true,
+	"from":         true,
+	"sha256":       true,
+	"source":       false,
+	"bootstrap":    true,
+	"fix":          false,
+	"format":       true,
+	"dry-run":      false,
+	"keep-patches": true,
+	"keep-used":    true,
+	"pins":         false,
+	"remove":       false,
+
+	"gotoolchain": true,
+}
+
+// arguments are the command line arguments after the command, without options.
+var arguments []string
+
+var options = make(map[string]string)
+
 var config struct {
 	GoHome string `yaml:"GOHOME" json:"GOHOME"`
 	GoRoot string `yaml:"GOROOT" json:"GOROOT"`
@@ -71,6 +235,7 @@ var config struct {
 }
 
 func init() {
+	usages["run"] = usages["exec"]
 	usage = func(command string) string {
 		if fn, exists := usages[command]; exists {
 			return fn()
@@ -87,6 +252,57 @@ func show(command string) {
 	}
 }
 
+// parseArgs splits args into arguments and options. Options are given as
+// --name=value or --name value, all args after "--" are arguments. Options
+// named like a config key, with "-" for "_", override the config.
+func parseArgs(args []string) (err error) {
+	for i := 0; i < len(args); i++ {
+		var arg = args[i]
+		if arg == "--" {
+			arguments = append(arguments, args[i+1:]...)
+			break
+		}
+
+		if len(arg) < 3 || !strings.HasPrefix(arg, "--") {
+			arguments = append(arguments, arg)
+			continue
+		}
+
+		var name, value = arg[2:], "true"
+		index := strings.Index(name, "=")
+		if index >= 0 {
+			name, value = name[:index], name[index+1:]
+		}
+
+		takes, exists := flags[name]
+		if !exists {
+			return fmt.Errorf("unknown option: --%s", name)
+		}
+
+		var key = strings.ReplaceAll(name, "-", "_")
+		if takes && index < 0 {
+			if i++; i >= len(args) {
+				return fmt.Errorf("option needs a value: --%s", name)
+			}
+			value = args[i]
+		}
+		// a switch which is no config key, like --source, takes no value
+		if !takes && index >= 0 && !conf.Has(key) {
+			return fmt.Errorf("option takes no value: --%s, pass %s as an argument", name, value)
+		}
+
+		options[name] = value
+
+		if conf.Has(key) {
+			if err = conf.Set(key, value); err != nil {
+				return fmt.Errorf("invalid option --%s: %w", name, err)
+			}
+		}
+	}
+
+	return
+}
+
 func initGvmRunCom() (err error) {
 	var filename = paths.GvmRunCom()
 
@@ -195,12 +411,37 @@ func showEnv() string {
 	return sb.String()
 }
 
-func initConfig() {
+func initGoHome() {
 	if config.GoHome, _ = utils.GetAbsEnv("GOHOME"); config.GoHome == "" {
 		if config.GoHome = os.Getenv("GOHOME"); config.GoHome == "" {
 			config.GoHome = golang.DefaultGoHome()
 		}
 	}
+}
+
+// initManager creates the manager of GOHOME, after the options are parsed.
+func initManager() {
+	var retry = conf.Retry
+	if retry == 0 {
+		retry = -1
+	}
+
+	manager = gvm.New(gvm.Options{
+		GoHome:      config.GoHome,
+		CacheDir:    conf.CacheDir,
+		Mirrors:     golang.ParseMirrors(conf.Mirror),
+		Offline:     conf.Offline,
+		IndexTTL:    conf.CacheTTL.Duration(),
+		Retry:       retry,
+		GitRemote:   conf.GitRemote,
+		GoToolchain: conf.GoToolchain,
+		Output:      messages,
+	})
+}
+
+func initConfig() {
+	initGoHome()
+
 	if config.GoPath = utils.Goenv("GOPATH"); config.GoPath == "" {
 		if config.GoPath = os.Getenv("GOPATH"); config.GoPath == "" {
 			config.GoPath = paths.Home("go")
@@ -215,9 +456,21 @@ func initConfig() {
 	}
 }
 
-func init() {
+// setup reads the configuration and the options of the command line.
+func setup() {
 	var err error
 
+	// shims run on every invocation of a go tool and the shell hook runs env
+	// on every directory change, they skip the environment setup, must not
+	// run go themselves nor write any file. The args of a shim belong to the
+	// tool.
+	if len(os.Args) > 1 && (os.Args[1] == "shim" || os.Args[1] == "env") {
+		command, arguments = os.Args[1], os.Args[2:]
+		initGoHome()
+		initManager()
+		return
+	}
+
 	// init config
 	initConfig()
 
@@ -232,47 +485,102 @@ func init() {
 	}
 
 	command = os.Args[1]
+	if err = parseArgs(os.Args[2:]); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if formatted() {
+		messages = os.Stderr
+	}
+	initManager()
+	if _, exists := options["refresh"]; exists {
+		manager.Refresh()
+	}
 	debug.Println(showEnv())
 }
 
-func exists(version string) bool {
-	binary := filepath.Join(config.GoHome, version, "bin", "go")
-	out, err := utils.Command(binary, "version")
+// manager manages the versions of GOHOME.
+var manager *gvm.Manager
+
+// messages receives the progress of commands, stderr with --format so
+// stdout holds only the formatted output.
+var messages io.Writer = os.Stdout
+
+func formatted() bool {
+	_, exists := options["format"]
+	return exists
+}
+
+// output writes v in the --format format and exits on error.
+func output(v interface{}) {
+	if err := format.Write(os.Stdout, options["format"], v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// resolveVersion resolves a version query with the manager, telling what
+// a query which is not exact resolved to.
+func resolveVersion(query string) (version string, err error) {
+	if version, err = manager.Resolve(query); err != nil {
+		if query == "tip" {
+			err = fmt.Errorf("%w, run: %s install tip", err, os.Args[0])
+		}
+		return
+	}
+
+	if c, err := golang.ParseConstraint(query); err == nil && !c.IsExact() {
+		fmt.Fprintln(os.Stderr, query, "resolved to", version)
+	}
+
+	return
+}
+
+// resolve is resolveVersion exiting on error.
+func resolve(query string) string {
+	version, err := resolveVersion(query)
 	if err != nil {
-		return false
+		fmt.Println(err)
+		os.Exit(1)
 	}
-	return strings.HasPrefix(out, fmt.Sprintf("go version %s %s/%s", version, runtime.GOOS, runtime.GOARCH))
+
+	return version
+}
+
+// versionArg returns the version argument, or the version pinned by the
+// project of the working directory.
+func versionArg(index int) string {
+	if len(arguments) > index {
+		return arguments[index]
+	}
+
+	if pin, ok := project.Find("."); ok {
+		fmt.Fprintln(messages, pin.Version, "pinned by", pin.File)
+		remember(pin.File)
+		return pin.Version
+	}
+
+	return args(index)
 }
 
 func args(index int) string {
-	if len(os.Args) > index+2 {
-		return os.Args[index+2]
+	if len(arguments) > index {
+		return arguments[index]
 	}
-	show(os.Args[1])
+	show(command)
 	os.Exit(1)
 	return ""
 }
 
 func set() {
-	var version = args(0)
-
-	if !exists(version) {
-		fmt.Println(version, "not found, will be install")
-		install()
-		if !exists(version) {
-			fmt.Println(version, "install failed")
-			return
-		}
-	}
+	var version = resolve(versionArg(0))
 
-	filename := filepath.Join(config.GoHome, version)
-	if err := utils.SetAbsEnv("GOROOT", filename); err != nil {
-		panic(err)
-	}
+	ensure(version, os.Stdout)
 
-	if err := utils.AddPath(filepath.Join(filename, "bin"), config.GoHome); err != nil {
+	if err := manager.Activate(version); err != nil {
 		panic(err)
 	}
+	filename := manager.Root(version)
 
 	fmt.Println("GOHOME:", config.GoHome)
 	fmt.Println("GOROOT:", filename)
@@ -280,18 +588,12 @@ func set() {
 }
 
 func use() {
-	var version = args(0)
+	var version = resolve(versionArg(0))
 
-	if !exists(version) {
-		fmt.Println(version, "not found, will be install")
-		install()
-		if !exists(version) {
-			fmt.Println(version, "install failed")
-			return
-		}
-	}
+	ensure(version, os.Stdout)
+	markUsed(version)
 
-	filename := filepath.Join(config.GoHome, version)
+	filename := manager.Root(version)
 	// TODO 设置环境变量
 
 	source := fmt.Sprintf("export GOROOT=\"%s\"\n", filename)
@@ -305,6 +607,18 @@ func use() {
 }
 
 func info() {
+	if formatted() {
+		output(struct {
+			GoOS      string `json:"GOOS"`
+			GoArch    string `json:"GOARCH"`
+			GoVersion string `json:"GOVERSION"`
+			GoHome    string `json:"GOHOME"`
+			GoRoot    string `json:"GOROOT"`
+			GoPath    string `json:"GOPATH"`
+		}{runtime.GOOS, runtime.GOARCH, runtime.Version(), config.GoHome, config.GoRoot, config.GoPath})
+		return
+	}
+
 	if out, err := utils.Command("go", "version"); err == nil && out != "" {
 		fmt.Println("GOOS:", runtime.GOOS)
 		fmt.Println("GOARCH:", runtime.GOARCH)
@@ -317,26 +631,28 @@ func info() {
 }
 
 func list() {
-	var version string
-	if root, err := utils.GetAbsEnv("GOROOT"); err == nil {
-		_, version = filepath.Split(strings.TrimSpace(root))
+	var versions = manager.List()
+
+	if formatted() {
+		output(versions)
+		return
 	}
 
 	var buf strings.Builder
 
-	for _, ver := range golang.GoVersionsList() {
-		var line = ver
-		if exists(ver) {
-			if filepath.Clean(config.GoRoot) == filepath.Join(config.GoHome, ver) {
-				line = fmt.Sprintf("> \033[1;32m%s\033[0m", ver)
+	for _, ver := range versions {
+		var line string
+		if ver.Installed {
+			if ver.Active {
+				line = fmt.Sprintf("> \033[1;32m%s\033[0m", ver.Version)
 			} else {
-				line = fmt.Sprintf("+ \033[1;36m%s\033[0m", ver)
+				line = fmt.Sprintf("+ \033[1;36m%s\033[0m", ver.Version)
 			}
 		} else {
-			line = fmt.Sprintf("- \033[1;37m%s\033[0m", ver)
+			line = fmt.Sprintf("- \033[1;37m%s\033[0m", ver.Version)
 		}
-		if ver == version {
-			line += "(system)"
+		if ver.System {
+			line += fmt.Sprintf("(system: %s)", ver.Path)
 		}
 		buf.WriteString(line)
 		buf.WriteString("\n")
@@ -345,58 +661,746 @@ func list() {
 	fmt.Print(buf.String())
 }
 
+// result is the outcome of installing a version.
+type result struct {
+	version string
+	status  string
+	reason  string
+	err     error
+}
+
+// report is a result in the --format output of install, uninstall and
+// prune.
+type report struct {
+	Version string `json:"version"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func reports(results []result) []report {
+	var reports = make([]report, 0, len(results))
+	for _, r := range results {
+		if r.err != nil {
+			reports = append(reports, report{Version: r.version, Status: "failed", Error: r.err.Error()})
+		} else {
+			reports = append(reports, report{Version: r.version, Status: r.status, Reason: r.reason})
+		}
+	}
+	return reports
+}
+
 func install() {
-	if len(os.Args) < 3 {
-		show(command)
+	var results []result
+	if source, exists := options["from"]; exists {
+		if len(arguments) > 0 {
+			fmt.Println("--from installs the version of its VERSION file, no version argument")
+			os.Exit(1)
+		}
+
+		var r = result{version: source}
+		if version, status, err := manager.InstallFrom(context.Background(), source, options["sha256"]); err != nil {
+			r.err = err
+		} else {
+			r.version, r.status = version, status
+		}
+		results = append(results, r)
+	} else if _, exists := options["source"]; exists {
+		var sources = arguments
+		if len(sources) < 1 {
+			sources = []string{versionArg(0)}
+		}
+
+		// builds run one by one, each uses all cpus
+		for _, source := range sources {
+			var r = result{version: source}
+			if version, status, err := manager.InstallSource(context.Background(), source, installOptions()); err != nil {
+				r.err = err
+			} else {
+				r.version, r.status = version, status
+			}
+			results = append(results, r)
+		}
+	} else {
+		results = installQueries()
+	}
+
+	var failed []string
+	var changed bool
+	for _, r := range results {
+		if r.err != nil {
+			if !formatted() {
+				fmt.Println(r.version, "failed:", r.err)
+			}
+			failed = append(failed, r.version)
+			continue
+		}
+		if !formatted() {
+			fmt.Println(r.version, r.status)
+		}
+		changed = changed || r.status == "installed"
+	}
+
+	if changed {
+		refreshShims()
+		trimCache()
+	}
+
+	if formatted() {
+		output(reports(results))
+		if len(failed) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(failed) > 0 {
+		fmt.Printf("%d of %d failed: %s\n", len(failed), len(results), strings.Join(failed, " "))
 		os.Exit(1)
 	}
+}
 
-	for _, version := range os.Args[2:] {
-		if exists(version) {
-			fmt.Println(version, "already installed")
-			return
+// installQueries resolves and installs the version arguments.
+func installQueries() (results []result) {
+	var queries = arguments
+	if len(queries) < 1 {
+		queries = []string{versionArg(0)}
+	}
+
+	var versions []string
+	var seen = make(map[string]bool)
+	for _, query := range queries {
+		if gvm.IsBuildQuery(query) {
+			var r = result{version: query}
+			if version, status, err := manager.InstallBuild(context.Background(), query, installOptions()); err != nil {
+				r.err = err
+			} else {
+				r.version, r.status = version, status
+			}
+			results = append(results, r)
+			continue
+		}
+
+		version, err := resolveVersion(query)
+		if err != nil {
+			results = append(results, result{version: query, err: err})
+			continue
 		}
+		if !seen[version] {
+			seen[version] = true
+			versions = append(versions, version)
+		}
+	}
 
-		var dir = filepath.Join(config.GoHome, version)
-		var filename = dir + "." + golang.Suffix()
-		var url = fmt.Sprintf("https://dl.google.com/go/%s", golang.Filename(version))
+	return append(results, installVersions(versions)...)
+}
 
-		fmt.Println(version, "installing: ")
-		if err := utils.Download(url, filename); err != nil {
-			panic(err)
+// installVersions installs versions with conf.Jobs workers.
+func installVersions(versions []string) []result {
+	var results []result
+	for _, r := range manager.InstallVersions(context.Background(), versions, conf.Jobs, installOptions()) {
+		results = append(results, result{version: r.Version, status: r.Status, err: r.Err})
+	}
+	return results
+}
+
+// installOptions returns the install options of the command line.
+func installOptions() gvm.InstallOptions {
+	return gvm.InstallOptions{
+		Stream:      conf.Stream,
+		KeepArchive: conf.KeepArchive,
+		Bootstrap:   options["bootstrap"],
+	}
+}
+
+// ensure installs version if it is missing, reporting to w, and exits if
+// the install fails.
+func ensure(version string, w io.Writer) {
+	if manager.Exists(version) {
+		return
+	}
+
+	fmt.Fprintln(w, version, "not found, will be install")
+	if _, err := manager.Install(context.Background(), version, installOptions()); err != nil {
+		fmt.Fprintln(w, version, "install failed:", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(w, version, "installed")
+
+	refreshShims()
+	trimCache()
+}
+
+// trimCache prunes the archive cache to conf.CacheSize, if it is limited.
+func trimCache() {
+	if conf.CacheSize <= 0 {
+		return
+	}
+
+	if _, err := manager.PruneArchives(int64(conf.CacheSize)); err != nil {
+		fmt.Fprintln(messages, "cache prune error:", err)
+	}
+}
+
+// markUsed records that version is used now, for prune.
+func markUsed(version string) {
+	if err := manager.MarkUsed(version); err != nil {
+		debug.Println("mark used error:", err.Error())
+	}
+}
+
+// remember records the project file filename, so prune keeps the version
+// it pins.
+func remember(filename string) {
+	if err := manager.Remember(filename); err != nil {
+		debug.Println("remember project error:", err.Error())
+	}
+}
+
+func update() {
+	var query = "tip"
+	if len(arguments) > 0 {
+		query = arguments[0]
+	}
+
+	var previous, _ = manager.ResolveBuild(query)
+	version, status, err := manager.InstallBuild(context.Background(), query, installOptions())
+	if err != nil {
+		fmt.Println(query, "update failed:", err)
+		os.Exit(1)
+	}
+
+	if status == "already installed" {
+		fmt.Println(version, "is up to date")
+		return
+	}
+
+	fmt.Println(version, status)
+	if previous != "" && previous != version {
+		fmt.Println(previous, "is kept, remove it with:", os.Args[0], "uninstall", previous)
+	}
+
+	refreshShims()
+}
+
+func adopt() {
+	var roots = arguments
+	var scan = len(roots) == 0
+	if scan {
+		roots = manager.Scan(config.GoRoot)
+	}
+
+	var changed, failed bool
+	for _, root := range roots {
+		version, status, err := manager.Adopt(root)
+		if err != nil {
+			if scan {
+				fmt.Println(root, "skipped:", err)
+				continue
+			}
+			fmt.Println(root, "failed:", err)
+			failed = true
+			continue
 		}
 
-		fmt.Println(version, "unpacking: ")
-		if err := golang.Decode(filename, config.GoHome); err != nil {
-			panic(err)
+		fmt.Printf("%s %s: %s\n", version, status, root)
+		changed = changed || status == "adopted"
+	}
+
+	if scan && len(roots) == 0 {
+		fmt.Println("no go installation found")
+	}
+
+	if changed {
+		refreshShims()
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// problem is a finding of doctor in the --format output.
+type problem struct {
+	Problem string `json:"problem"`
+	Fix     string `json:"fix"`
+	Fixable bool   `json:"fixable"`
+	Fixed   bool   `json:"fixed"`
+	Error   string `json:"error,omitempty"`
+}
+
+func doctor() {
+	_, fix := options["fix"]
+	version, source := manager.Active(".")
+
+	fmt.Fprintln(messages, "GOHOME:", config.GoHome)
+	if version != "" {
+		fmt.Fprintf(messages, "selected: %s (set by %s)\n", version, source)
+	}
+
+	var findings = manager.Doctor(".")
+
+	var left int
+	var problems = make([]problem, 0, len(findings))
+	for _, f := range findings {
+		var p = problem{Problem: f.Problem, Fix: f.Suggest, Fixable: f.Fix != nil}
+		if fix && f.Fix != nil {
+			if err := f.Fix(); err != nil {
+				p.Error = err.Error()
+			} else {
+				p.Fixed = true
+			}
+		}
+		if !p.Fixed {
+			left++
+		}
+		problems = append(problems, p)
+	}
+
+	if formatted() {
+		output(problems)
+		if left > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	for _, p := range problems {
+		fmt.Println("-", p.Problem)
+		switch {
+		case p.Error != "":
+			fmt.Println("  fix failed:", p.Error)
+		case p.Fixed:
+			fmt.Println("  fixed:", p.Fix)
+		case p.Fixable:
+			fmt.Println("  fix (--fix):", p.Fix)
+		default:
+			fmt.Println("  fix:", p.Fix)
+		}
+	}
+
+	if left == 0 {
+		fmt.Println("no problems found")
+		return
+	}
+
+	if left == 1 {
+		fmt.Println("1 problem found")
+	} else {
+		fmt.Printf("%d problems found\n", left)
+	}
+	os.Exit(1)
+}
+
+// prune uninstalls the released versions in GOHOME which no retention rule
+// keeps, see the prune usage.
+func prune() {
+	_, dryRun := options["dry-run"]
+
+	var pruned = manager.Prune(gvm.PruneOptions{
+		KeepUsed:    conf.KeepUsed.Duration(),
+		KeepPatches: conf.KeepPatches,
+		DryRun:      dryRun,
+	})
+
+	var results []result
+	var freed int64
+	for _, p := range pruned {
+		results = append(results, result{version: p.Version, status: p.Status, reason: p.Reason, err: p.Err})
+		freed += p.Size
+	}
+
+	if !dryRun {
+		refreshShims()
+	}
+
+	if formatted() {
+		output(reports(results))
+		return
+	}
+
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			fmt.Println(r.version, "remove failed:", r.err)
+		case r.reason != "":
+			fmt.Printf("%s %s, %s\n", r.version, r.status, r.reason)
+		default:
+			fmt.Println(r.version, r.status)
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("%s would be freed\n", conf.Size(freed))
+	} else {
+		fmt.Printf("%s freed\n", conf.Size(freed))
+	}
+}
+
+func outdated() {
+	upgrades, err := manager.Outdated(".")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if formatted() {
+		output(upgrades)
+		return
+	}
+
+	if len(upgrades) == 0 {
+		fmt.Println("all installed versions are up to date")
+		return
+	}
+
+	for _, u := range upgrades {
+		var line = fmt.Sprintf("%s -> %s", u.Version, u.Latest)
+		if u.Active {
+			line += " (active)"
 		}
+		fmt.Println(line)
+	}
+}
+
+// confirm asks question on a terminal, it is false if stdin is not one.
+func confirm(question string) bool {
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return false
+	}
 
-		if err := os.Rename(filepath.Join(config.GoHome, "go"), dir); err != nil {
+	fmt.Printf("%s [y/N] ", question)
+	var answer string
+	fmt.Scanln(&answer)
+
+	return strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+}
+
+func upgrade() {
+	var old string
+	if len(arguments) > 0 {
+		// only an installed version is upgraded, never the newest remote one
+		var err error
+		if old, err = golang.Resolve(arguments[0], manager.Installed()); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	} else if old, _ = manager.Active("."); old == "" {
+		show(command)
+		os.Exit(1)
+	}
+	if !manager.Exists(old) {
+		fmt.Println(old, "is not installed")
+		os.Exit(1)
+	}
+
+	var version = manager.NewestPatch(old)
+	if version == old {
+		fmt.Println(old, "is up to date")
+		return
+	}
+
+	fmt.Println(old, "upgrading to", version)
+	var r = installVersions([]string{version})[0]
+	if r.err != nil {
+		fmt.Println(version, "failed:", r.err)
+		os.Exit(1)
+	}
+	fmt.Println(version, r.status)
+	if r.status == "installed" {
+		refreshShims()
+		trimCache()
+	}
+
+	if manager.Global() == old {
+		if err := manager.Activate(version); err != nil {
 			panic(err)
 		}
+		fmt.Println(version, "is the global version")
+	}
 
-		fmt.Println(version, "installed")
+	if _, exists := options["pins"]; exists {
+		for _, pin := range manager.UpgradePins(".", old, version) {
+			if pin.Err != nil {
+				fmt.Println(pin.File, "pin upgrade failed:", pin.Err)
+			} else {
+				fmt.Println(version, "pinned in", pin.File)
+			}
+		}
 	}
+
+	if _, remove := options["remove"]; !remove && !confirm(fmt.Sprintf("remove %s?", old)) {
+		fmt.Println(old, "is kept, remove it with:", os.Args[0], "uninstall", old)
+		return
+	}
+
+	status, err := manager.Uninstall(old)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(old, status)
 }
 
 func uninstall() {
-	if len(os.Args) < 3 {
+	if len(arguments) < 1 {
 		show(command)
 		os.Exit(1)
 	}
 
-	for _, version := range os.Args[2:] {
-		if exists(version) {
-			if err := os.RemoveAll(filepath.Join(config.GoHome, version)); err != nil {
-				panic(err)
+	var results []result
+	for _, version := range arguments {
+		var root = manager.Root(version)
+		status, err := manager.Uninstall(version)
+		if err != nil {
+			panic(err)
+		}
+
+		if !formatted() {
+			if status == "unregistered" {
+				fmt.Println(version, "unregistered, adopted", root, "is kept")
+			} else {
+				fmt.Println(version, status)
 			}
-			_ = os.RemoveAll(filepath.Join(config.GoHome, version+".tar.gz"))
 		}
+		results = append(results, result{version: version, status: status})
+	}
 
-		fmt.Println(version, "uninstalled")
+	if formatted() {
+		output(reports(results))
 	}
 }
 
+func local() {
+	var version = resolve(args(0))
+
+	dir, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+
+	filename, err := project.Write(dir, version)
+	if err != nil {
+		panic(err)
+	}
+
+	remember(filename)
+
+	fmt.Println(version, "pinned in", filename)
+	if !manager.Exists(version) {
+		fmt.Println(version, "is not installed, run:", os.Args[0], "install")
+	}
+}
+
+func current() {
+	version, source := manager.Active(".")
+	if version == "" {
+		fmt.Fprintln(messages, "no go version selected")
+		os.Exit(1)
+	}
+
+	if formatted() {
+		output(struct {
+			gvm.Version
+			Source string `json:"source"`
+		}{manager.Describe(version), source})
+		return
+	}
+
+	var line = fmt.Sprintf("%s (set by %s)", version, source)
+	if !manager.Exists(version) {
+		line += " not installed"
+	}
+
+	fmt.Println(line)
+}
+
+func execute() {
+	if len(arguments) < 2 {
+		show(command)
+		os.Exit(1)
+	}
+
+	var version = resolve(arguments[0])
+	ensure(version, os.Stderr)
+	markUsed(version)
+
+	var env = manager.Env(version)
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			// look up the command in the PATH of the version
+			os.Setenv("PATH", kv[len("PATH="):])
+		}
+	}
+
+	if err := utils.Exec(arguments[1], arguments[2:], env); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// refreshShims rewrites the shims after an install, if they are used.
+func refreshShims() {
+	if err := manager.RefreshShims(); err != nil {
+		fmt.Fprintln(messages, "rehash error:", err)
+	}
+}
+
+func rehash() {
+	tools, err := manager.Rehash()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("shims:", strings.Join(tools, " "))
+
+	var dir = manager.ShimsDir()
+	for _, p := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(p) == dir {
+			return
+		}
+	}
+	fmt.Println("add", dir, "to the front of PATH to use them")
+}
+
+// shim runs a tool of the active version, it is run by the launchers.
+func shim() {
+	if len(arguments) < 1 {
+		show("rehash")
+		os.Exit(1)
+	}
+
+	version, source := manager.Active(".")
+	if version == "" {
+		fmt.Fprintln(os.Stderr, "gvm: no go version selected, run:", os.Args[0], "set <version>")
+		os.Exit(1)
+	}
+
+	if !files.IsDir(manager.Root(version)) {
+		fmt.Fprintln(os.Stderr, "gvm:", version, "selected by", source, "is not installed, run:", os.Args[0], "install", version)
+		os.Exit(1)
+	}
+
+	var tool = filepath.Join(manager.Root(version), "bin", arguments[0])
+	if err := utils.Exec(tool, arguments[1:], manager.Env(version)); err != nil {
+		fmt.Fprintln(os.Stderr, "gvm:", err)
+		os.Exit(1)
+	}
+}
+
+// archivesSize returns the total size of the cached archives entries.
+func archivesSize(entries []gvm.Archive) (size int64) {
+	for _, entry := range entries {
+		size += entry.Size
+	}
+	return
+}
+
+// archives runs the cache command.
+func archives() {
+	var removed []gvm.Archive
+	var err error
+
+	switch args(0) {
+	case "list":
+		entries, err := manager.Archives()
+		if err != nil {
+			panic(err)
+		}
+		if formatted() {
+			output(entries)
+			return
+		}
+		for _, entry := range entries {
+			var line = fmt.Sprintf("%.12s  %-36s %8s  %s", entry.Sha256, entry.Name, conf.Size(entry.Size), entry.Used.Format("2006-01-02 15:04"))
+			if entry.Partial {
+				line += " (partial)"
+			}
+			fmt.Println(line)
+		}
+		fmt.Printf("%d archives, %s in %s\n", len(entries), conf.Size(archivesSize(entries)), manager.CacheDir())
+		return
+	case "prune":
+		var max = conf.CacheSize
+		if value, exists := options["max-size"]; exists {
+			if max, err = conf.ParseSize(value); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		} else if max <= 0 {
+			show(command)
+			os.Exit(1)
+		}
+		removed, err = manager.PruneArchives(int64(max))
+	case "clean":
+		removed, err = manager.CleanArchives()
+	default:
+		show(command)
+		os.Exit(1)
+	}
+
+	for _, entry := range removed {
+		fmt.Println("removed", entry.Name)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("%d archives, %s freed\n", len(removed), conf.Size(archivesSize(removed)))
+}
+
+func hook() {
+	script, err := shell.Hook(args(0), paths.AbsThisFile())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Print(script)
+}
+
+// env prints the statements switching the current shell session to the
+// active version, it is run by the shell hook.
+func env() {
+	var sh = "bash"
+	if len(arguments) > 0 {
+		sh = arguments[0]
+	}
+
+	var lines []string
+	var line string
+	var err error
+
+	version, source := manager.Active(".")
+	switch {
+	case version != "" && !manager.Exists(version):
+		fmt.Fprintln(os.Stderr, "gvm:", version, "selected by", source, "is not installed")
+		return
+	case version != "":
+		if line, err = shell.Export(sh, "GOROOT", manager.Root(version)); err != nil {
+			break
+		}
+		lines = append(lines, line)
+		line, err = shell.Export(sh, "PATH", manager.Path(os.Getenv("PATH"), version))
+		lines = append(lines, line)
+	default:
+		if root := os.Getenv("GOROOT"); root != "" && filepath.Dir(filepath.Clean(root)) == filepath.Clean(config.GoHome) {
+			if line, err = shell.Unset(sh, "GOROOT"); err != nil {
+				break
+			}
+			lines = append(lines, line)
+		}
+		line, err = shell.Export(sh, "PATH", manager.Path(os.Getenv("PATH"), ""))
+		lines = append(lines, line)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gvm:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(strings.Join(lines, "\n"))
+}
+
 func help() {
 	if fn, exists := usages[command]; exists {
 		fmt.Println(fn())
@@ -407,6 +1411,8 @@ func help() {
 }
 
 func main() {
+	setup()
+
 	switch command {
 	case "set":
 		set()
@@ -416,6 +1422,34 @@ func main() {
 		info()
 	case "list":
 		list()
+	case "local":
+		local()
+	case "current":
+		current()
+	case "hook":
+		hook()
+	case "env":
+		env()
+	case "exec", "run":
+		execute()
+	case "rehash":
+		rehash()
+	case "cache":
+		archives()
+	case "update":
+		update()
+	case "adopt":
+		adopt()
+	case "doctor":
+		doctor()
+	case "prune":
+		prune()
+	case "outdated":
+		outdated()
+	case "upgrade":
+		upgrade()
+	case "shim":
+		shim()
 	case "help":
 		help()
 	case "install":
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestMain runs gvm itself when GVM_TEST_MAIN is set, so tests can run the
+// test binary as the gvm command.
+func TestMain(m *testing.M) {
+	if os.Getenv("GVM_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// run runs gvm with args in the home dir home and returns its output.
+func run(home string, args ...string) (string, error) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GVM_TEST_MAIN=1", "HOME="+home, "GOHOME="+filepath.Join(home, "gohome"), "SHELL=/bin/bash", "GVM_OFFLINE=true")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestEnvWritesNothing(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the environment is persisted in the registry")
+	}
+
+	var home = t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(home, ".bashrc"), []byte("# empty\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := run(home, "env", "bash"); err != nil {
+		t.Fatalf("env: %v: %s", err, out)
+	}
+
+	entries, err := ioutil.ReadDir(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != ".bashrc" {
+			t.Errorf("env wrote %s", entry.Name())
+		}
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(home, ".bashrc")); err != nil || string(data) != "# empty\n" {
+		t.Errorf("env changed .bashrc: %q, %v", data, err)
+	}
+}
+
+func TestSwitchValue(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the environment is persisted in the registry")
+	}
+
+	out, err := run(t.TempDir(), "install", "--source=go1.21.5")
+	if err == nil || !strings.Contains(out, "option takes no value: --source") {
+		t.Errorf("install --source=go1.21.5: got %v: %s", err, out)
+	}
+}